@@ -0,0 +1,157 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+)
+
+// exportCache maps the hash of a result file content to its evaluated detail
+// rows, so that incremental exports only evaluate new or changed files. The
+// cache is discarded when the export file or the CLI version changes. The
+// cache is encrypted by the cipher decrypting the results, as it contains the
+// decrypted values.
+type exportCache struct {
+	Hash    string                 `json:"hash"`
+	Entries map[string]*fileDetail `json:"entries"`
+
+	fileName string
+	// seen is the hashes of result files handled in this run, other entries
+	// are removed on save
+	seen   map[string]bool
+	misses int
+}
+
+func loadExportCache(fileName string, hash string) (*exportCache, error) {
+	cache := &exportCache{
+		Hash:     hash,
+		Entries:  make(map[string]*fileDetail),
+		fileName: fileName,
+		seen:     make(map[string]bool),
+	}
+
+	f, err := os.ReadFile(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read export cache %s: %w", fileName, err)
+	}
+
+	if store.IsEncrypted(f) {
+		if resultCipher == nil {
+			pterm.Warning.Println("Encrypted export cache without the decryption key, discarding:", fileName)
+			return cache, nil
+		}
+
+		if f, err = resultCipher.Decrypt(f); err != nil {
+			pterm.Warning.Println("Fail to decrypt export cache, discarding:", err)
+			return cache, nil
+		}
+	}
+
+	var stored exportCache
+	if err := json.Unmarshal(f, &stored); err != nil {
+		pterm.Warning.Println("Fail to decode export cache, discarding:", err)
+		return cache, nil
+	}
+
+	if stored.Hash != hash {
+		pterm.Debug.Println("Export file or CLI version changed, discarding export cache:", fileName)
+		return cache, nil
+	}
+
+	if stored.Entries != nil {
+		cache.Entries = stored.Entries
+	}
+
+	return cache, nil
+}
+
+// newMemoryExportCache creates an export cache which is never saved
+func newMemoryExportCache(hash string) *exportCache {
+	return &exportCache{
+		Hash:    hash,
+		Entries: make(map[string]*fileDetail),
+		seen:    make(map[string]bool),
+	}
+}
+
+// get returns the cached detail, the cache is disabled if not loaded
+func (c *exportCache) get(hash string) (*fileDetail, bool) {
+	if c.Entries == nil {
+		return nil, false
+	}
+
+	c.seen[hash] = true
+
+	detail, ok := c.Entries[hash]
+	if !ok {
+		c.misses++
+	}
+
+	return detail, ok
+}
+
+func (c *exportCache) put(hash string, detail *fileDetail) {
+	if c.Entries == nil {
+		return
+	}
+
+	c.Entries[hash] = detail
+}
+
+func (c *exportCache) save() error {
+	for hash := range c.Entries {
+		if !c.seen[hash] {
+			delete(c.Entries, hash)
+		}
+	}
+
+	c.seen = make(map[string]bool)
+	c.misses = 0
+
+	if c.fileName == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.fileName), 0755); err != nil {
+		return fmt.Errorf("fail to mkdir cache directory: %w", err)
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("fail to marshal export cache: %w", err)
+	}
+
+	if resultCipher != nil {
+		if b, err = resultCipher.Encrypt(b); err != nil {
+			return fmt.Errorf("fail to encrypt export cache: %w", err)
+		}
+	}
+
+	// the cache contains the values of the results as the result files
+	if err := os.WriteFile(c.fileName, b, 0600); err != nil {
+		return fmt.Errorf("fail to write export cache %s: %w", c.fileName, err)
+	}
+
+	// the permissions of an existing cache are not changed by the write
+	if err := os.Chmod(c.fileName, 0600); err != nil {
+		return fmt.Errorf("fail to chmod export cache %s: %w", c.fileName, err)
+	}
+
+	return nil
+}
+
+func (f *resultFile) hash() string {
+	hash := sha256.Sum256(f.data)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,295 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffConfig   string
+	diffKeys     []string
+	diffJSONFile string
+)
+
+var ErrNoDiffKeys = errors.New("no key columns to match rows, expect --config or --key")
+
+func newDiffCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "diff [old.csv] [new.csv]",
+		Short:   "Show the difference between two exported detail files",
+		Example: "demo export diff export/store/example_detail_1.csv export/store/example_detail_2.csv --config example",
+		Args:    cobra.ExactArgs(2),
+		Run:     runDiff,
+	}
+
+	cmd.Flags().StringVarP(&diffConfig, "config", "c", "", "The export config to match rows by its key columns")
+	cmd.Flags().StringSliceVarP(&diffKeys, "key", "k", nil, "The titles to match rows, instead of the key columns of the export config")
+	cmd.Flags().StringVar(&diffJSONFile, "json", "", "Also write the difference into the JSON file")
+
+	return cmd
+}
+
+// exportDiff is the difference between two exported detail files, rows are
+// matched by the values of the key columns.
+type exportDiff struct {
+	Keys    []string            `json:"keys"`
+	Added   []map[string]string `json:"added"`
+	Removed []map[string]string `json:"removed"`
+	Changed []*rowDiff          `json:"changed"`
+}
+
+type rowDiff struct {
+	Row   map[string]string `json:"row"`
+	Cells []*cellDiff       `json:"cells"`
+}
+
+type cellDiff struct {
+	Column string `json:"column"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// detailTable is an exported detail file with the header row
+type detailTable struct {
+	titles []string
+	rows   [][]string
+}
+
+func runDiff(_ *cobra.Command, args []string) {
+	keys := diffKeys
+	if len(keys) == 0 && diffConfig != "" {
+		exp, err := loadExportFile("assets/" + diffConfig + ".json")
+		if err != nil {
+			pterm.Fatal.Println("Fail to load export file:", err)
+		}
+
+		for _, keyColumn := range exp.KeyColumns {
+			keys = append(keys, exp.Titles[keyColumn.index].Title)
+		}
+	}
+
+	if len(keys) == 0 {
+		pterm.Fatal.Println("Fail to diff:", ErrNoDiffKeys)
+	}
+
+	oldTable, err := loadDetailTable(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to load old detail file:", err)
+	}
+
+	newTable, err := loadDetailTable(args[1])
+	if err != nil {
+		pterm.Fatal.Println("Fail to load new detail file:", err)
+	}
+
+	diff, err := diffDetailTables(keys, oldTable, newTable)
+	if err != nil {
+		pterm.Fatal.Println("Fail to diff detail files:", err)
+	}
+
+	if err := diff.print(); err != nil {
+		pterm.Fatal.Println("Fail to print difference:", err)
+	}
+
+	if diffJSONFile != "" {
+		if err := diff.exportJSON(diffJSONFile); err != nil {
+			pterm.Fatal.Println("Fail to export difference:", err)
+		}
+	}
+}
+
+func loadDetailTable(fileName string) (*detailTable, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open detail file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("fail to read detail file %s: %w", fileName, err)
+	}
+
+	if len(records) == 0 {
+		return &detailTable{}, nil
+	}
+
+	return &detailTable{titles: records[0], rows: records[1:]}, nil
+}
+
+func (t *detailTable) columnIndex(title string) int {
+	for i, t := range t.titles {
+		if t == title {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// index maps the row key into the row, keyed by the title of each column
+func (t *detailTable) index(keys []string) (map[string]map[string]string, []string, error) {
+	keyIndexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		i := t.columnIndex(key)
+		if i < 0 {
+			return nil, nil, fmt.Errorf("key column %s: %w", key, ErrUnknownExportTitle)
+		}
+
+		keyIndexes = append(keyIndexes, i)
+	}
+
+	rows := make(map[string]map[string]string)
+	order := make([]string, 0, len(t.rows))
+	for _, row := range t.rows {
+		values := make([]string, 0, len(keyIndexes))
+		for _, i := range keyIndexes {
+			values = append(values, row[i])
+		}
+		rowKey := strings.Join(values, "\x00")
+
+		if _, ok := rows[rowKey]; ok {
+			pterm.Warning.Println("Duplicate row key, only the last row is compared:", strings.Join(values, ", "))
+		} else {
+			order = append(order, rowKey)
+		}
+
+		rows[rowKey] = make(map[string]string)
+		for i, title := range t.titles {
+			if i < len(row) {
+				rows[rowKey][title] = row[i]
+			}
+		}
+	}
+
+	return rows, order, nil
+}
+
+func diffDetailTables(keys []string, oldTable, newTable *detailTable) (*exportDiff, error) {
+	oldRows, oldOrder, err := oldTable.index(keys)
+	if err != nil {
+		return nil, fmt.Errorf("fail to index old detail rows: %w", err)
+	}
+
+	newRows, newOrder, err := newTable.index(keys)
+	if err != nil {
+		return nil, fmt.Errorf("fail to index new detail rows: %w", err)
+	}
+
+	diff := &exportDiff{
+		Keys:    keys,
+		Added:   make([]map[string]string, 0),
+		Removed: make([]map[string]string, 0),
+		Changed: make([]*rowDiff, 0),
+	}
+
+	keyRow := func(row map[string]string) map[string]string {
+		values := make(map[string]string)
+		for _, key := range keys {
+			values[key] = row[key]
+		}
+
+		return values
+	}
+
+	// columns of both files, in the order of the new file
+	columns := append([]string(nil), newTable.titles...)
+	for _, title := range oldTable.titles {
+		if newTable.columnIndex(title) < 0 {
+			columns = append(columns, title)
+		}
+	}
+
+	for _, rowKey := range newOrder {
+		newRow := newRows[rowKey]
+
+		oldRow, ok := oldRows[rowKey]
+		if !ok {
+			diff.Added = append(diff.Added, newRow)
+			continue
+		}
+
+		cells := make([]*cellDiff, 0)
+		for _, column := range columns {
+			if oldRow[column] != newRow[column] {
+				cells = append(cells, &cellDiff{Column: column, Old: oldRow[column], New: newRow[column]})
+			}
+		}
+
+		if len(cells) > 0 {
+			diff.Changed = append(diff.Changed, &rowDiff{Row: keyRow(newRow), Cells: cells})
+		}
+	}
+
+	for _, rowKey := range oldOrder {
+		if _, ok := newRows[rowKey]; !ok {
+			diff.Removed = append(diff.Removed, oldRows[rowKey])
+		}
+	}
+
+	return diff, nil
+}
+
+func (d *exportDiff) formatRow(row map[string]string) string {
+	values := make([]string, 0, len(d.Keys))
+	for _, key := range d.Keys {
+		values = append(values, row[key])
+	}
+
+	return strings.Join(values, ", ")
+}
+
+func (d *exportDiff) print() error {
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		pterm.Success.Println("No difference.")
+		return nil
+	}
+
+	data := [][]string{{strings.Join(d.Keys, ", "), "Status", "Column", "Old", "New"}}
+
+	for _, row := range d.Added {
+		data = append(data, []string{d.formatRow(row), pterm.Green("added"), "", "", ""})
+	}
+
+	for _, row := range d.Removed {
+		data = append(data, []string{d.formatRow(row), pterm.Red("removed"), "", "", ""})
+	}
+
+	for _, row := range d.Changed {
+		for _, cell := range row.Cells {
+			data = append(data, []string{d.formatRow(row.Row), pterm.Yellow("changed"), cell.Column, cell.Old, cell.New})
+		}
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+		return fmt.Errorf("fail to render difference table: %w", err)
+	}
+
+	pterm.Info.Printf("%d added, %d removed, %d changed\n", len(d.Added), len(d.Removed), len(d.Changed))
+
+	return nil
+}
+
+func (d *exportDiff) exportJSON(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to create diff file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "\t")
+
+	if err := encoder.Encode(d); err != nil {
+		return fmt.Errorf("fail to write difference: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+func newExplainCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "explain [path] [key]",
+		Short:   "Explain which rules and titles match a result key",
+		Example: "demo export explain example loopSelectInputScoring.g1s1",
+		Args:    cobra.ExactArgs(2),
+		Run:     runExplain,
+	}
+
+	return cmd
+}
+
+func runExplain(_ *cobra.Command, args []string) {
+	exp, err := loadExportFile("assets/" + args[0] + ".json")
+	if err != nil {
+		pterm.Fatal.Println("Fail to load export file:", err)
+	}
+
+	key := args[1]
+	// a metadata key such as `meta.createdAt` is only evaluated by the meta
+	// rules
+	applied := make(map[*exportRule]bool)
+	metaKey := strings.TrimPrefix(key, "meta.")
+	if _, ok := (&store.Result{}).Metadata()[metaKey]; ok && metaKey != key {
+		for _, rule := range exp.Rules {
+			if rule.Meta != "" && rule.metaKey() == key {
+				applied[rule] = true
+			}
+		}
+	} else {
+		applied = ruleSet(exp.getRules(key))
+	}
+
+	pterm.DefaultSection.Println("Rules matching " + key)
+
+	ruleRows := [][]string{{"#", "Priority", "Regexp", "Type", "Continue", "Result", "Title", "Rows"}}
+	stopped := false
+	for _, rule := range exp.Rules {
+		result := "not matched"
+		title, rows := "", ""
+
+		switch {
+		case applied[rule]:
+			result = "applied"
+			if rule.Meta == "" && !rule.Continue {
+				stopped = true
+			}
+
+			if t := exp.getRuleTitle(key, rule); t != nil {
+				title = t.Title
+			} else {
+				title = "(no title, skipped)"
+			}
+
+			rows = explainRowIndexes(exp, key, rule)
+		case rule.Meta != "":
+			result = "meta rule, only applied on " + rule.metaKey()
+		case rule.regexp.MatchString(key) && stopped:
+			result = "matched, but a previous rule does not continue"
+		}
+
+		ruleRows = append(ruleRows, []string{
+			strconv.Itoa(rule.index),
+			strconv.Itoa(rule.Priority),
+			rule.Regexp,
+			string(rule.Type),
+			strconv.FormatBool(rule.Continue),
+			result,
+			title,
+			rows,
+		})
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(ruleRows).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render rules table:", err)
+	}
+
+	pterm.DefaultSection.Println("Titles matching " + key)
+
+	used := exp.getTitle(key)
+	titleRows := [][]string{{"#", "Title", "Regexp", "Field", "Result"}}
+	for _, title := range exp.Titles {
+		result := "not matched"
+
+		switch {
+		case title.Aggregate != nil:
+			result = "aggregate, never matches keys"
+		case title == used:
+			result = "used by rules without title"
+		case title.regexp.MatchString(key):
+			result = "matched, but a previous title is used"
+		}
+
+		field := string(title.Field)
+		if field == "" {
+			field = string(store.AnswerFieldValue)
+		}
+
+		titleRows = append(titleRows, []string{strconv.Itoa(title.index), title.Title, title.Regexp, field, result})
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(titleRows).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render titles table:", err)
+	}
+
+	if len(applied) == 0 {
+		pterm.Warning.Println("No match rule, the key is skipped on export:", key)
+	}
+}
+
+func ruleSet(rules []*exportRule) map[*exportRule]bool {
+	set := make(map[*exportRule]bool)
+	for _, rule := range rules {
+		set[rule] = true
+	}
+
+	return set
+}
+
+// explainRowIndexes describes the rows of a group the rule writes into
+func explainRowIndexes(exp *exporter, key string, rule *exportRule) string {
+	indexes, err := exp.getForRowIndexes(key, rule)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+
+	rows := make([]string, 0, len(indexes))
+	for _, idx := range indexes {
+		rows = append(rows, strconv.Itoa(idx+1))
+	}
+
+	return strings.Join(rows, ",")
+}
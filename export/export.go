@@ -2,21 +2,50 @@ package export
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
-	"regexp"
 	"time"
 
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/justin0u0/NTHU-OS-Demo/version"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 var (
 	exportDir    string
-	storeDir     string
+	storeDirs    []string
+	recursive    bool
 	filterRegexp string
+	audit        bool
+	stats        bool
+	lenient      bool
+	statsBins    int
+	incremental  bool
+	cacheDir     string
+	stableName   bool
+	watch        bool
+	interval     time.Duration
+	rosterFile   string
+	rosterColumn string
+
+	filterCreatedBy     []string
+	filterCreatedAfter  string
+	filterCreatedBefore string
+	filterVersion       string
+	filterWhere         []string
+
+	verifyKeyFile    string
+	rejectUnverified bool
+	decryptKey       string
+	passphrase       bool
+
+	// resultCipher decrypts the encrypted result files, nil if no key is given
+	resultCipher *store.Cipher
 )
 
 //go:embed assets
@@ -32,61 +61,176 @@ func NewExportCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&exportDir, "export", "e", "export/store", "The directory to store the exported csv files")
-	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory to load all result files")
+	cmd.Flags().StringSliceVarP(&storeDirs, "store", "s", []string{"record/store"}, "The directories, glob patterns, .zip/.tar.gz archives or SQLite databases (.db) to load all result files")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Load result files in the subdirectories of the store directories")
 	cmd.Flags().StringVarP(&filterRegexp, "filter", "f", ".*\\.json", "The regex pattern to filter files")
+	cmd.Flags().BoolVar(&audit, "audit", false, "Also export a JSON file mapping each cell to its source result file and rule")
+	cmd.Flags().BoolVar(&lenient, "lenient", false, "Skip the result files failed to export and report them instead of aborting")
+	cmd.Flags().BoolVar(&stats, "stats", false, "Print statistics of the numeric columns and export them into the summary file")
+	cmd.Flags().IntVar(&statsBins, "stats-bins", 10, "The number of histogram bins of the statistics")
+	cmd.Flags().BoolVar(&incremental, "incremental", false, "Only evaluate new or changed result files, others are loaded from the cache")
+	cmd.Flags().StringVar(&cacheDir, "cache", "export/cache", "The directory to store the export cache")
+	cmd.Flags().BoolVar(&stableName, "stable-name", false, "Export files without the timestamp in the file name, overwriting the previous export")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "Watch the stores and re-export when result files are added or modified, the failed result files are skipped and shown in the progress")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "The interval to check the stores in watch mode")
+	cmd.Flags().StringVar(&rosterFile, "roster", "", "The file of student ids, one per line or in the first csv column, to show the progress in watch mode")
+	cmd.Flags().StringVar(&rosterColumn, "roster-column", "", "The title index, id or name matching the roster ids, defaults to the first key column")
+	cmd.Flags().StringSliceVar(&filterCreatedBy, "created-by", nil, "Only export results created by the given TAs")
+	cmd.Flags().StringVar(&filterCreatedAfter, "created-after", "", "Only export results created at or after the time (RFC3339 or 2006-01-02)")
+	cmd.Flags().StringVar(&filterCreatedBefore, "created-before", "", "Only export results created before the time, a date is included (RFC3339 or 2006-01-02)")
+	cmd.Flags().StringVar(&filterVersion, "version", "", "Only export results recorded by the given CLI version")
+	cmd.Flags().StringArrayVar(&filterWhere, "where", nil, "Only export results whose key equals the value (key=value)")
+	cmd.Flags().StringVar(&verifyKeyFile, "verify-key", "", "The TA key file to verify the signatures of the results, unsigned or modified results are reported")
+	cmd.Flags().BoolVar(&rejectUnverified, "reject-unverified", false, "Fail on unsigned or modified results instead of reporting them, requires --verify-key")
+	cmd.Flags().StringVar(&decryptKey, "decrypt-key", "", "The key file to decrypt the encrypted results")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Ask for the passphrase to decrypt the encrypted results")
+
+	cmd.AddCommand(newExplainCommand())
+	cmd.AddCommand(newDiffCommand())
 
 	return cmd
 }
 
 func run(_ *cobra.Command, args []string) {
-	resultFileNames, err := loadResultFiles()
-	if err != nil {
-		pterm.Fatal.Println("Fail to load result file:", err)
-	}
-
 	exp, err := loadExportFile("assets/" + args[0] + ".json")
 	if err != nil {
 		pterm.Fatal.Println("Fail to load export file:", err)
 	}
 
-	for _, resultFileName := range resultFileNames {
-		if err := handleResultFile(resultFileName, exp); err != nil {
-			pterm.Fatal.Println("Fail to handle result file:", err)
+	if resultCipher, err = store.LoadCipher(decryptKey, passphrase); err != nil {
+		pterm.Fatal.Println("Fail to load decryption key:", err)
+	}
+
+	cache := &exportCache{}
+	if incremental {
+		cache, err = loadExportCache(fmt.Sprintf("%s/%s.json", cacheDir, args[0]), exp.hash)
+		if err != nil {
+			pterm.Fatal.Println("Fail to load export cache:", err)
 		}
 	}
 
-	if err := os.MkdirAll(exportDir, 0755); err != nil {
-		pterm.Fatal.Println("Fail to mkdir export directory:", err)
+	if watch {
+		if cache.Entries == nil {
+			cache = newMemoryExportCache(exp.hash)
+		}
+
+		runWatch(args[0], exp, cache)
+		return
 	}
 
-	exportDetailFileName := fmt.Sprintf("%s/%s_detail_%d.csv", exportDir, args[0], time.Now().Unix())
-	if err := exp.exportDetailRowsCSV(exportDetailFileName); err != nil {
-		pterm.Fatal.Println("Fail to export detail file:", err)
+	resultFiles, err := loadResultFiles()
+	if err != nil {
+		pterm.Fatal.Println("Fail to load result file:", err)
+	}
+
+	if _, err := runExport(args[0], exp, cache, resultFiles); err != nil {
+		pterm.Fatal.Println("Fail to export:", err)
 	}
 }
 
-func loadResultFiles() ([]string, error) {
-	files, err := os.ReadDir(storeDir)
+// runExport evaluates the result files and writes all the export files. The
+// result files failed to export are skipped and reported if `--lenient` or
+// `--watch` is set.
+func runExport(name string, exp *exporter, cache *exportCache, resultFiles []*resultFile) (*exportReport, error) {
+	exp.reset()
+
+	filter, err := newResultFilter()
 	if err != nil {
-		return nil, fmt.Errorf("fail to read the store directory %s: %w", storeDir, err)
+		return nil, fmt.Errorf("fail to create result filter: %w", err)
 	}
 
-	fileNames := make([]string, 0)
-
-	re, err := regexp.Compile(filterRegexp)
+	verifier, err := newResultVerifier()
 	if err != nil {
-		return nil, fmt.Errorf("fail to compile regexp %s: %w", filterRegexp, err)
+		return nil, fmt.Errorf("fail to create result verifier: %w", err)
 	}
 
-	for _, file := range files {
-		if !re.MatchString(file.Name()) {
+	report := &exportReport{Failed: make([]*resultFileError, 0)}
+
+	for _, resultFile := range resultFiles {
+		exported, err := handleResultFile(resultFile, exp, filter, verifier, cache)
+		if err != nil {
+			if !lenient && !watch {
+				return nil, fmt.Errorf("fail to handle result file: %w", err)
+			}
+
+			pterm.Error.Println("Fail to handle result file, skipping:", err)
+			report.Failed = append(report.Failed, &resultFileError{File: resultFile.name, Error: err.Error()})
 			continue
 		}
 
-		fileNames = append(fileNames, storeDir+"/"+file.Name())
+		if exported {
+			report.Exported++
+		}
+	}
+
+	report.Unverified = verifier.unverified
+
+	if cache.Entries != nil {
+		pterm.Info.Printf("Evaluated %d of %d result files, others are loaded from the cache\n", cache.misses, len(resultFiles))
+
+		if err := cache.save(); err != nil {
+			return nil, fmt.Errorf("fail to save export cache: %w", err)
+		}
+	}
+
+	exp.evaluateAggregates()
+
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		return nil, fmt.Errorf("fail to mkdir export directory: %w", err)
+	}
+
+	exportTime := time.Now().Unix()
+	exportFileName := func(kind string, ext string) string {
+		if stableName || watch {
+			return fmt.Sprintf("%s/%s_%s.%s", exportDir, name, kind, ext)
+		}
+
+		return fmt.Sprintf("%s/%s_%s_%d.%s", exportDir, name, kind, exportTime, ext)
 	}
 
-	return fileNames, nil
+	exportDetailFileName := exportFileName("detail", "csv")
+	if err := exp.exportDetailRowsCSV(exportDetailFileName); err != nil {
+		return nil, fmt.Errorf("fail to export detail file: %w", err)
+	}
+
+	if lenient || watch || verifier.key != nil {
+		exportReportFileName := exportFileName("report", "json")
+		if err := report.exportJSON(exportReportFileName); err != nil {
+			return nil, fmt.Errorf("fail to export report file: %w", err)
+		}
+
+		if len(report.Failed) > 0 {
+			pterm.Warning.Printf("%d of %d result files failed to export, see %s\n", len(report.Failed), len(resultFiles), exportReportFileName)
+		}
+
+		if len(report.Unverified) > 0 {
+			pterm.Warning.Printf("%d of %d result files are unsigned or modified, see %s\n", len(report.Unverified), len(resultFiles), exportReportFileName)
+		}
+	}
+
+	if audit {
+		exportAuditFileName := exportFileName("audit", "json")
+		if err := exp.exportAuditCellsJSON(exportAuditFileName); err != nil {
+			return nil, fmt.Errorf("fail to export audit file: %w", err)
+		}
+	}
+
+	if stats {
+		if statsBins < 1 {
+			return nil, fmt.Errorf("expect at least 1 histogram bin, got %d", statsBins)
+		}
+
+		if err := printStats(exp.evaluateStats(statsBins)); err != nil {
+			return nil, fmt.Errorf("fail to print statistics: %w", err)
+		}
+
+		exportSummaryFileName := exportFileName("summary", "csv")
+		if err := exp.exportSummaryRowsCSV(exportSummaryFileName); err != nil {
+			return nil, fmt.Errorf("fail to export summary file: %w", err)
+		}
+	}
+
+	return report, nil
 }
 
 func loadExportFile(fileName string) (*exporter, error) {
@@ -102,6 +246,10 @@ func loadExportFile(fileName string) (*exporter, error) {
 		return nil, fmt.Errorf("fail to decode export file %s: %w", fileName, err)
 	}
 
+	// the evaluation depends on both the export file and the CLI version
+	hash := sha256.Sum256(append(f, version.Version...))
+	exp.hash = hex.EncodeToString(hash[:])
+
 	pterm.Debug.Println("Load export file content:", exp)
 
 	if err := exp.compile(); err != nil {
@@ -111,24 +259,43 @@ func loadExportFile(fileName string) (*exporter, error) {
 	return &exp, nil
 }
 
-func handleResultFile(fileName string, exp *exporter) error {
+// handleResultFile evaluates the result file into the exporter, returns false
+// if the result file is skipped by the filter
+func handleResultFile(resultFile *resultFile, exp *exporter, filter *resultFilter, verifier *resultVerifier, cache *exportCache) (bool, error) {
+	fileName := resultFile.name
+
 	pterm.Debug.Println("Handling result file:", fileName)
 
-	f, err := os.ReadFile(fileName)
+	r, err := store.DecodeWith(resultFile.data, resultCipher)
 	if err != nil {
-		return fmt.Errorf("fail to read result file %s: %w", fileName, err)
+		return false, fmt.Errorf("fail to decode reuslt file %s: %w", fileName, err)
 	}
 
-	result := make(map[string]interface{})
-	if err := json.NewDecoder(bytes.NewReader(f)).Decode(&result); err != nil {
-		return fmt.Errorf("fail to decode reuslt file %s: %w", fileName, err)
-	}
+	result := r.Flatten()
 
 	pterm.Debug.Println("Load result file content:", result)
 
-	if err := exp.evaluateDetail(result); err != nil {
-		return fmt.Errorf("fail to evaluate detail: %w", err)
+	if !filter.match(r, result) {
+		pterm.Debug.Println("Result file does not match the filters, skipping:", fileName)
+		return false, nil
+	}
+
+	if err := verifier.verify(fileName, r); err != nil {
+		return false, err
+	}
+
+	hash := resultFile.hash()
+	detail, ok := cache.get(hash)
+	if !ok {
+		detail, err = exp.evaluateDetail(fileName, r)
+		if err != nil {
+			return false, fmt.Errorf("fail to evaluate detail %s: %w", fileName, err)
+		}
+
+		cache.put(hash, detail)
 	}
 
-	return nil
+	exp.insertDetailRows(fileName, detail)
+
+	return true, nil
 }
@@ -8,7 +8,9 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"time"
 
+	"github.com/justin0u0/NTHU-OS-Demo/store"
 	"github.com/pterm/pterm"
 )
 
@@ -17,20 +19,36 @@ type exporter struct {
 	Titles    []*exportTitle `json:"titles"`
 	Rules     []*exportRule  `json:"rules"`
 
-	// KeyColumns is the list of titles index to identify that 2 files belongs
-	// to the same row and should merge information into the same row
-	KeyColumns []int `json:"keyColumns"`
-	// SortColumns is the list of titles index to give the order of the
-	// `detailRows` and `summaryRows`
-	SortColumns []int `json:"sortColumns"`
-
-	detailRows  [][]string
-	summaryRows [][]string
+	// KeyColumns is the list of titles index, id or name to identify that 2
+	// files belongs to the same row and should merge information into the
+	// same row
+	KeyColumns []*exportColumnRef `json:"keyColumns"`
+	// SortColumns is the list of titles index, id or name, or sort column
+	// objects with order and collation to give the order of the `detailRows`
+	SortColumns []*exportSortColumn `json:"sortColumns"`
+	// Coerce is the list of coercions applied to result values mismatching
+	// the rule type
+	Coerce []exportCoercion `json:"coerce"`
+
+	detailRows [][]string
+	// detailValues is the numeric values of the valuable rules on each key of
+	// the `detailRows` merged into the same row, for aggregate titles to refer
+	// to
+	detailValues []map[string]float64
+	summaryRows  [][]string
+	auditCells   []*auditCell
+
+	// hash identifies the export file, to invalidate the export cache
+	hash string
 }
 
 var (
 	ErrInvalidExportRuleType      = errors.New("invalid export rule type")
 	ErrRusultTypeMismatchRuleType = errors.New("result type mismatch rule type")
+	ErrUnknownExportTitle         = errors.New("unknown export title")
+	ErrUnknownExportMetaKey       = errors.New("unknown export meta key")
+	ErrInvalidExportRuleFor       = errors.New("invalid export rule for, expect all or a named group of the regexp")
+	ErrExportRuleWritesKeyColumn  = errors.New("export rule writes into a key column, expect a plaintext rule")
 )
 
 type exportRule struct {
@@ -38,8 +56,27 @@ type exportRule struct {
 	Type   exportRuleType `json:"type"`
 	Value  int            `json:"value"`
 	For    string         `json:"for"`
+	// Deadline is the deadline and penalty for the rule type deadline
+	Deadline *exportDeadline `json:"deadline"`
+	// Options maps string values, such as select option values or
+	// descriptions, into numbers for the valuable rule types
+	Options map[string]float64 `json:"options"`
+	// Priority orders the rules, a rule with higher priority matches first,
+	// rules with the same priority keep the order in the config
+	Priority int `json:"priority"`
+	// Continue also applies the next matching rules on the key
+	Continue bool `json:"continue"`
+	// Title is the title index, id or name written by the rule, instead of
+	// the title matching the key
+	Title *exportColumnRef `json:"title"`
+	// Meta is the metadata key evaluated by the rule once for each result,
+	// such as `createdAt` for deadline rules. A meta rule never matches the
+	// answer keys, and its key is `meta.<key>` in the export.
+	Meta string `json:"meta"`
 
 	regexp *regexp.Regexp
+	title  *exportTitle
+	index  int
 }
 
 type exportRuleType string
@@ -49,27 +86,97 @@ var (
 	exportRuleTypeValuableBoolean  exportRuleType = "valuable_boolean"
 	exportRuleTypeValuableComplete exportRuleType = "valuable_complete"
 	exportRuleTypeVaulablePartial  exportRuleType = "valuable_partial"
+	exportRuleTypeDeadline         exportRuleType = "deadline"
+	exportRuleTypeThreshold        exportRuleType = "threshold"
 )
 
+// metaKey returns the key of the metadata evaluated by a meta rule
+func (r *exportRule) metaKey() string {
+	return "meta." + r.Meta
+}
+
+// isValuable returns true if the rule evaluates the key into a score
+func (r *exportRule) isValuable() bool {
+	switch r.Type {
+	case exportRuleTypeValuableBoolean, exportRuleTypeValuableComplete, exportRuleTypeVaulablePartial:
+		return true
+	}
+
+	return false
+}
+
 type exportTitle struct {
+	// Id is the stable id to refer to the title, defaults to the title
+	Id      string `json:"id"`
 	Title   string `json:"title"`
 	Regexp  string `json:"regexp"`
 	Default string `json:"default"`
+	// Aggregate computes the column from other columns or keys instead of
+	// copying a matched key
+	Aggregate *exportAggregate `json:"aggregate"`
+	// Max caps the numeric value of the column
+	Max *float64 `json:"max"`
+	// Round is the number of decimal places of the numeric value of the column
+	Round *int `json:"round"`
+	// Field is the field of the select answers written into the column, one
+	// of value, label or index, defaults to value
+	Field store.AnswerField `json:"field"`
 
 	regexp *regexp.Regexp
 	index  int
 }
 
+// reset clears the rows evaluated by the previous export
+func (e *exporter) reset() {
+	e.detailRows = nil
+	e.detailValues = nil
+	e.summaryRows = nil
+	e.auditCells = nil
+}
+
 func (e *exporter) compile() error {
-	for _, rule := range e.Rules {
+	for _, coercion := range e.Coerce {
+		if err := coercion.validate(); err != nil {
+			return err
+		}
+	}
+
+	for i, rule := range e.Rules {
+		rule.index = i
+
 		regexp, err := regexp.Compile(rule.Regexp)
 		if err != nil {
 			return fmt.Errorf("fail to compile rule %s: %w", rule.Regexp, err)
 		}
 
 		rule.regexp = regexp
+
+		if rule.Meta != "" {
+			if _, ok := (&store.Result{}).Metadata()[rule.Meta]; !ok {
+				return fmt.Errorf("rule %s: %s: %w", rule.Regexp, rule.Meta, ErrUnknownExportMetaKey)
+			}
+
+			// the group of a meta rule is matched on its key `meta.<key>`
+			if rule.For != "all" {
+				group := rule.regexp.SubexpIndex(rule.For)
+				if matches := rule.regexp.FindStringSubmatch(rule.metaKey()); group < 1 || matches == nil {
+					return fmt.Errorf("rule %s on %s for %s: %w", rule.Regexp, rule.metaKey(), rule.For, ErrInvalidExportRuleFor)
+				}
+			}
+		}
+
+		if rule.Type == exportRuleTypeDeadline {
+			if rule.Deadline == nil {
+				return fmt.Errorf("rule %s expect deadline: %w", rule.Regexp, ErrNoExportDeadline)
+			}
+
+			if err := rule.Deadline.compile(); err != nil {
+				return fmt.Errorf("fail to compile deadline of rule %s: %w", rule.Regexp, err)
+			}
+		}
 	}
 
+	ids := make(map[string]bool)
 	for i, title := range e.Titles {
 		regexp, err := regexp.Compile(title.Regexp)
 		if err != nil {
@@ -78,85 +185,235 @@ func (e *exporter) compile() error {
 
 		title.regexp = regexp
 		title.index = i
+
+		if err := title.Field.Validate(); err != nil {
+			return fmt.Errorf("fail to compile title %s: %w", title.Title, err)
+		}
+
+		if title.Id != "" {
+			if ids[title.Id] {
+				return fmt.Errorf("%s: %w", title.Id, ErrDuplicateExportTitleId)
+			}
+
+			ids[title.Id] = true
+		}
 	}
 
-	return nil
-}
+	if err := resolveColumnRefs(e.KeyColumns, e.Titles); err != nil {
+		return fmt.Errorf("fail to compile key columns: %w", err)
+	}
 
-func (e *exporter) evaluateDetail(result map[string]interface{}) error {
-	detail := make(map[string]interface{})
+	for _, title := range e.Titles {
+		if title.Aggregate != nil {
+			if err := title.Aggregate.compile(e.Titles); err != nil {
+				return fmt.Errorf("fail to compile aggregate of title %s: %w", title.Title, err)
+			}
+		}
+	}
 
-	for k, v := range result {
-		rule := e.getRule(k)
+	for _, sortColumn := range e.SortColumns {
+		if err := sortColumn.compile(e.Titles); err != nil {
+			return fmt.Errorf("fail to compile sort column: %w", err)
+		}
+	}
 
-		if rule == nil {
-			pterm.Warning.Println("No match rule, skipping key:", k)
+	for _, rule := range e.Rules {
+		if rule.Title == nil {
 			continue
 		}
 
-		switch rule.Type {
-		case exportRuleTypePlainText:
-			value, ok := v.(string)
-			if !ok {
-				return fmt.Errorf("rule %s expect type string on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
-			}
+		title, err := rule.Title.resolve(e.Titles)
+		if err != nil {
+			return fmt.Errorf("fail to compile title of rule %s: %w", rule.Regexp, err)
+		}
 
-			detail[k] = value
+		rule.title = title
 
-		case exportRuleTypeValuableBoolean:
-			value, ok := v.(bool)
-			if !ok {
-				return fmt.Errorf("rule %s expect type bool on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
-			}
+		if e.isKeyColumn(title.index) && rule.Type != exportRuleTypePlainText {
+			return fmt.Errorf("rule %s on title %s: %w", rule.Regexp, title.Title, ErrExportRuleWritesKeyColumn)
+		}
+	}
+
+	sort.SliceStable(e.Rules, func(i, j int) bool {
+		return e.Rules[i].Priority > e.Rules[j].Priority
+	})
+
+	return nil
+}
+
+// detailValue is the value evaluated by a rule on a result key
+type detailValue struct {
+	key   string
+	rule  *exportRule
+	raw   interface{}
+	value interface{}
+}
 
-			detail[k] = 0
-			if value {
-				detail[k] = rule.Value
+// fileDetail is the detail rows evaluated from a single result file, before
+// merging into the detail rows of the exporter
+type fileDetail struct {
+	Rows       [][]string           `json:"rows"`
+	Values     []map[string]float64 `json:"values"`
+	AuditCells []*auditCell         `json:"auditCells"`
+}
+
+// evaluateDetail evaluates the answers of the result by the rules matching the
+// answer keys, and the metadata of the result by the meta rules
+func (e *exporter) evaluateDetail(fileName string, r *store.Result) (*fileDetail, error) {
+	result := r.Flatten()
+	detail := make([]*detailValue, 0)
+
+	// the keys are evaluated in order, so that the same cell written by
+	// several keys always gets the value of the last key
+	for _, k := range r.Keys() {
+		v := result[k]
+		rules := e.getRules(k)
+
+		if len(rules) == 0 {
+			pterm.Warning.Println("No match rule, skipping key:", k)
+			continue
+		}
+
+		for _, rule := range rules {
+			ruleValue := v
+			if title := e.getRuleTitle(k, rule); title != nil && title.Field != "" {
+				if answer, ok := r.Answers[k]; ok {
+					ruleValue = answer.Field(title.Field)
+				}
 			}
 
-		case exportRuleTypeValuableComplete:
-			value, ok := v.(float64)
-			if !ok {
-				return fmt.Errorf("rule %s expect type float64 on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+			value, err := e.evaluateRule(rule, k, ruleValue, result)
+			if err != nil {
+				return nil, err
 			}
 
-			detail[k] = value
+			detail = append(detail, &detailValue{key: k, rule: rule, raw: v, value: value})
+		}
+	}
 
-		case exportRuleTypeVaulablePartial:
-			value, ok := v.(float64)
-			if !ok {
-				return fmt.Errorf("rule %s expect type float64 on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
-			}
+	metadata := r.Metadata()
+	for _, rule := range e.Rules {
+		if rule.Meta == "" {
+			continue
+		}
 
-			detail[k] = value * float64(rule.Value)
+		// the results without the metadata are skipped, such as the legacy
+		// results without the created time
+		k, v := rule.metaKey(), metadata[rule.Meta]
+		if v == "" {
+			continue
+		}
 
-		default:
-			return ErrInvalidExportRuleType
+		value, err := e.evaluateRule(rule, k, v, result)
+		if err != nil {
+			return nil, err
 		}
+
+		detail = append(detail, &detailValue{key: k, rule: rule, raw: v, value: value})
 	}
 
 	pterm.Debug.Println("Evaluate detail done:", detail)
 
-	if err := e.insertDetailRows(detail); err != nil {
-		return fmt.Errorf("fail to insert detail rows: %w", err)
+	detailRows, detailValues, auditCells, err := e.getDetailRows(detail)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get detail rows: %w", err)
 	}
 
-	return nil
+	for _, cell := range auditCells {
+		cell.File = fileName
+		cell.Row = e.getRowKeyValues(detailRows[cell.rowIndex])
+	}
+
+	return &fileDetail{Rows: detailRows, Values: detailValues, AuditCells: auditCells}, nil
 }
 
-func (e *exporter) insertDetailRows(detail map[string]interface{}) error {
-	detailRows, err := e.getDetailRows(detail)
-	if err != nil {
-		return fmt.Errorf("fail to get detail rows: %w", err)
+func (e *exporter) evaluateRule(rule *exportRule, k string, v interface{}, result map[string]interface{}) (interface{}, error) {
+	v = e.coerce(rule, v)
+
+	switch rule.Type {
+	case exportRuleTypePlainText:
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type string on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		return value, nil
+
+	case exportRuleTypeValuableBoolean:
+		value, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type bool on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		if value {
+			return rule.Value, nil
+		}
+
+		return 0, nil
+
+	case exportRuleTypeValuableComplete:
+		value, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type float64 on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		return value, nil
+
+	case exportRuleTypeVaulablePartial:
+		value, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type float64 on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		return value * float64(rule.Value), nil
+
+	case exportRuleTypeThreshold:
+		value, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type float64 on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		return value >= float64(rule.Value), nil
+
+	case exportRuleTypeDeadline:
+		value, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("rule %s expect type string on key %s: %w", rule.Type, k, ErrRusultTypeMismatchRuleType)
+		}
+
+		createdAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s expect RFC3339 timestamp on key %s: %w", rule.Type, k, err)
+		}
+
+		penalty, err := rule.Deadline.evaluate(createdAt, result)
+		if err != nil {
+			return nil, fmt.Errorf("fail to evaluate deadline on key %s: %w", k, err)
+		}
+
+		return penalty, nil
+	}
+
+	return nil, ErrInvalidExportRuleType
+}
+
+// insertDetailRows merges the detail rows of a result file into the exporter,
+// the file detail is not modified so that it can be cached
+func (e *exporter) insertDetailRows(fileName string, detail *fileDetail) {
+	// the cached cells may be inserted by another file of the same content
+	for _, cell := range detail.AuditCells {
+		c := *cell
+		c.File = fileName
+		e.auditCells = append(e.auditCells, &c)
 	}
 
-	for _, newRow := range detailRows {
+	for newRowIndex, newRow := range detail.Rows {
 		isNewRow := true
 
 		newRowKey := e.getRowKey(newRow)
 		pterm.Debug.Println("inserting detail rows with row key:", newRowKey)
 
-		for _, existsRow := range e.detailRows {
+		for existsRowIndex, existsRow := range e.detailRows {
 			existsRowKey := e.getRowKey(existsRow)
 
 			if newRowKey == existsRowKey {
@@ -169,15 +426,23 @@ func (e *exporter) insertDetailRows(detail map[string]interface{}) error {
 						existsRow[i] = newRow[i]
 					}
 				}
+
+				for k, v := range detail.Values[newRowIndex] {
+					e.detailValues[existsRowIndex][k] = v
+				}
 			}
 		}
 
 		if isNewRow {
-			e.detailRows = append(e.detailRows, newRow)
+			values := make(map[string]float64)
+			for k, v := range detail.Values[newRowIndex] {
+				values[k] = v
+			}
+
+			e.detailRows = append(e.detailRows, append([]string(nil), newRow...))
+			e.detailValues = append(e.detailValues, values)
 		}
 	}
-
-	return nil
 }
 
 func (e *exporter) getRowKey(row []string) string {
@@ -185,7 +450,7 @@ func (e *exporter) getRowKey(row []string) string {
 
 	for i := range row {
 		for _, keyColumn := range e.KeyColumns {
-			if i == keyColumn {
+			if i == keyColumn.index {
 				key += fmt.Sprintf("%d:%s;", i, row[i])
 			}
 		}
@@ -194,54 +459,100 @@ func (e *exporter) getRowKey(row []string) string {
 	return key
 }
 
-func (e *exporter) getDetailRows(detail map[string]interface{}) ([][]string, error) {
+// getRowKeyValues returns the values of the key columns of the row, keyed by
+// the title of the column
+func (e *exporter) getRowKeyValues(row []string) map[string]string {
+	values := make(map[string]string)
+
+	for _, keyColumn := range e.KeyColumns {
+		values[e.Titles[keyColumn.index].Title] = row[keyColumn.index]
+	}
+
+	return values
+}
+
+func (e *exporter) getDetailRows(detail []*detailValue) ([][]string, []map[string]float64, []*auditCell, error) {
 	rows := make([][]string, e.GroupSize)
+	values := make([]map[string]float64, e.GroupSize)
 	for i := range rows {
+		values[i] = make(map[string]float64)
+
 		rows[i] = make([]string, len(e.Titles))
 		for j := range rows[i] {
 			rows[i][j] = e.Titles[j].Default
 		}
 	}
 
-	for k, v := range detail {
-		rule := e.getRule(k)
-		if rule == nil {
-			pterm.Warning.Println("No match rule, skipping key:", k)
-			continue
+	auditCells := make([]*auditCell, 0)
+
+	for _, d := range detail {
+		k, v, rule := d.key, d.value, d.rule
+
+		rowIndexes, err := e.getForRowIndexes(k, rule)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("fail to get row indexes: %w", err)
+		}
+
+		// only the scores are aggregated, not the numeric plaintext such as
+		// the student ids
+		if rule.isValuable() {
+			if value, err := strconv.ParseFloat(fmt.Sprintf("%v", v), 64); err == nil {
+				for _, idx := range rowIndexes {
+					values[idx][k] = value
+				}
+			}
 		}
 
-		title := e.getTitle(k)
+		title := e.getRuleTitle(k, rule)
 		if title == nil {
 			pterm.Warning.Println("No match title, skipping key:", k)
 			continue
 		}
 
-		rowIndexes, err := e.getForRowIndexes(k, rule)
-		if err != nil {
-			return nil, fmt.Errorf("fail to get row indexes: %w", err)
+		// the scores written into a key column would split the rows of the
+		// same student, such as a title regexp also matching the score keys
+		if e.isKeyColumn(title.index) && rule.Type != exportRuleTypePlainText {
+			return nil, nil, nil, fmt.Errorf("rule %s on key %s into title %s: %w", rule.Regexp, k, title.Title, ErrExportRuleWritesKeyColumn)
 		}
 
 		for _, idx := range rowIndexes {
 			rows[idx][title.index] = fmt.Sprintf("%v", v)
+
+			auditCells = append(auditCells, &auditCell{
+				Column:   title.Title,
+				Key:      k,
+				Rule:     auditRule{Regexp: rule.Regexp, Meta: rule.Meta, Type: rule.Type},
+				Raw:      d.raw,
+				Value:    rows[idx][title.index],
+				rowIndex: idx,
+			})
 		}
 	}
 
-	return rows, nil
+	return rows, values, auditCells, nil
 }
 
-func (e *exporter) getRule(key string) *exportRule {
+// getRules returns the rules applied on the answer key, which are the matching
+// rules in the order of priority until one without `continue`
+func (e *exporter) getRules(key string) []*exportRule {
+	var rules []*exportRule
+
 	for _, rule := range e.Rules {
-		if rule.regexp.MatchString(key) {
-			return rule
+		if rule.Meta == "" && rule.regexp.MatchString(key) {
+			rules = append(rules, rule)
+
+			if !rule.Continue {
+				break
+			}
 		}
 	}
 
-	return nil
+	return rules
 }
 
 func (e *exporter) getTitle(key string) *exportTitle {
 	for _, title := range e.Titles {
-		if title.regexp.MatchString(key) {
+		if title.Aggregate == nil && title.regexp.MatchString(key) {
 			return title
 		}
 	}
@@ -249,6 +560,16 @@ func (e *exporter) getTitle(key string) *exportTitle {
 	return nil
 }
 
+// getRuleTitle returns the title of the rule if specified, or the title
+// matching the key otherwise
+func (e *exporter) getRuleTitle(key string, rule *exportRule) *exportTitle {
+	if rule.title != nil {
+		return rule.title
+	}
+
+	return e.getTitle(key)
+}
+
 func (e *exporter) getForRowIndexes(k string, rule *exportRule) ([]int, error) {
 	var indexes []int
 
@@ -263,6 +584,9 @@ func (e *exporter) getForRowIndexes(k string, rule *exportRule) ([]int, error) {
 	for i, name := range rule.regexp.SubexpNames() {
 		if i > 0 && name == rule.For {
 			matches := rule.regexp.FindStringSubmatch(k)
+			if matches == nil {
+				return nil, fmt.Errorf("rule %s does not match key %s for %s: %w", rule.Regexp, k, rule.For, ErrInvalidExportRuleFor)
+			}
 
 			groupId, err := strconv.Atoi(matches[i])
 			if err != nil {
@@ -286,6 +610,7 @@ func (e *exporter) exportDetailRowsCSV(fileName string) error {
 	if err != nil {
 		return fmt.Errorf("fail to create csv file: %w", err)
 	}
+	defer f.Close()
 
 	writer := csv.NewWriter(f)
 
@@ -302,11 +627,11 @@ func (e *exporter) exportDetailRowsCSV(fileName string) error {
 		detailRows:  e.detailRows,
 		sortColumns: e.SortColumns,
 	}
-	sort.Sort(detailRowSlice)
+	sort.Stable(detailRowSlice)
 
 	if err := writer.WriteAll(detailRowSlice.detailRows); err != nil {
 		return fmt.Errorf("fail to write detail rows: %w", err)
 	}
 
-	return nil
+	return writer.Error()
 }
@@ -0,0 +1,193 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// Aggregate titles are derived columns which are not filled by result keys,
+// but computed from other columns or from the scores of the keys matching a
+// regexp. They are evaluated after all result files are merged into the
+// detail rows, in the order of the titles, so an aggregate can refer to a
+// previous one.
+
+type exportAggregate struct {
+	Type exportAggregateType `json:"type"`
+	// Columns is the list of titles index, id or name to aggregate
+	Columns []*exportColumnRef `json:"columns"`
+	// Regexp aggregates the keys of the row matching the regexp, which are
+	// evaluated by the valuable rules
+	Regexp string `json:"regexp"`
+	// Weights is the weight of each column in `Columns`, only for weighted_sum
+	Weights []float64 `json:"weights"`
+	// Penalties is the list of titles index, id or name of penalty columns
+	// evaluated by deadline rules, applied after the maximum cap
+	Penalties []*exportColumnRef `json:"penalties"`
+
+	regexp *regexp.Regexp
+}
+
+var (
+	ErrInvalidExportAggregateType = errors.New("invalid export aggregate type")
+	ErrInvalidExportAggregate     = errors.New("invalid export aggregate")
+)
+
+type exportAggregateType string
+
+var (
+	exportAggregateTypeSum         exportAggregateType = "sum"
+	exportAggregateTypeAvg         exportAggregateType = "avg"
+	exportAggregateTypeMin         exportAggregateType = "min"
+	exportAggregateTypeMax         exportAggregateType = "max"
+	exportAggregateTypeWeightedSum exportAggregateType = "weighted_sum"
+)
+
+func (a *exportAggregate) compile(titles []*exportTitle) error {
+	switch a.Type {
+	case exportAggregateTypeSum, exportAggregateTypeAvg, exportAggregateTypeMin, exportAggregateTypeMax:
+	case exportAggregateTypeWeightedSum:
+		if len(a.Weights) != len(a.Columns) {
+			return fmt.Errorf("expect one weight for each column: %w", ErrInvalidExportAggregate)
+		}
+	default:
+		return ErrInvalidExportAggregateType
+	}
+
+	if err := resolveColumnRefs(a.Columns, titles); err != nil {
+		return fmt.Errorf("fail to compile aggregate columns: %w", err)
+	}
+
+	if err := resolveColumnRefs(a.Penalties, titles); err != nil {
+		return fmt.Errorf("fail to compile aggregate penalties: %w", err)
+	}
+
+	if a.Regexp != "" {
+		regexp, err := regexp.Compile(a.Regexp)
+		if err != nil {
+			return fmt.Errorf("fail to compile aggregate %s: %w", a.Regexp, err)
+		}
+
+		a.regexp = regexp
+	}
+
+	return nil
+}
+
+func (a *exportAggregate) evaluate(row []string, values map[string]float64) (float64, bool) {
+	var (
+		operands []float64
+		weights  []float64
+	)
+
+	for i, column := range a.Columns {
+		value, err := strconv.ParseFloat(row[column.index], 64)
+		if err != nil {
+			continue
+		}
+
+		operands = append(operands, value)
+		if a.Type == exportAggregateTypeWeightedSum {
+			weights = append(weights, a.Weights[i])
+		}
+	}
+
+	if a.regexp != nil {
+		for k, value := range values {
+			if a.regexp.MatchString(k) {
+				operands = append(operands, value)
+				weights = append(weights, 1)
+			}
+		}
+	}
+
+	if len(operands) == 0 {
+		return 0, false
+	}
+
+	var result float64
+
+	switch a.Type {
+	case exportAggregateTypeSum:
+		for _, value := range operands {
+			result += value
+		}
+	case exportAggregateTypeAvg:
+		for _, value := range operands {
+			result += value
+		}
+		result /= float64(len(operands))
+	case exportAggregateTypeMin:
+		result = math.Inf(1)
+		for _, value := range operands {
+			result = math.Min(result, value)
+		}
+	case exportAggregateTypeMax:
+		result = math.Inf(-1)
+		for _, value := range operands {
+			result = math.Max(result, value)
+		}
+	case exportAggregateTypeWeightedSum:
+		for i, value := range operands {
+			result += value * weights[i]
+		}
+	}
+
+	return result, true
+}
+
+// evaluateAggregates caps the columns, then fills the aggregate titles and
+// caps them. The other columns are capped first, so that an aggregate uses the
+// capped values of its columns wherever it is listed.
+func (e *exporter) evaluateAggregates() {
+	for i, row := range e.detailRows {
+		for _, title := range e.Titles {
+			if title.Aggregate == nil {
+				title.cap(row)
+			}
+		}
+
+		for _, title := range e.Titles {
+			if title.Aggregate == nil {
+				continue
+			}
+
+			if value, ok := title.Aggregate.evaluate(row, e.detailValues[i]); ok {
+				row[title.index] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+
+			title.cap(row)
+		}
+	}
+}
+
+// cap applies the maximum cap, the penalties and the rounding of the title on
+// the numeric cell of the row
+func (t *exportTitle) cap(row []string) {
+	if t.Max == nil && t.Round == nil && (t.Aggregate == nil || len(t.Aggregate.Penalties) == 0) {
+		return
+	}
+
+	value, err := strconv.ParseFloat(row[t.index], 64)
+	if err != nil {
+		return
+	}
+
+	if t.Max != nil {
+		value = math.Min(value, *t.Max)
+	}
+
+	if t.Aggregate != nil {
+		for _, penalty := range t.Aggregate.Penalties {
+			value = applyPenalty(value, row[penalty.index])
+		}
+	}
+
+	if t.Round != nil {
+		row[t.index] = strconv.FormatFloat(value, 'f', *t.Round, 64)
+	} else {
+		row[t.index] = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,44 @@
+package export
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestExporter(t *testing.T, config string) *exporter {
+	t.Helper()
+
+	var exp exporter
+	if err := json.Unmarshal([]byte(config), &exp); err != nil {
+		t.Fatalf("fail to decode exporter: %v", err)
+	}
+
+	if err := exp.compile(); err != nil {
+		t.Fatalf("fail to compile exporter: %v", err)
+	}
+
+	return &exp
+}
+
+func TestEvaluateAggregatesCapsColumnsBeforeAggregate(t *testing.T) {
+	exp := newTestExporter(t, `{
+		"groupSize": 1,
+		"titles": [
+			{"title": "Total", "aggregate": {"type": "sum", "columns": ["Score #1", "Score #2"]}},
+			{"title": "Score #1", "regexp": "^s1$", "max": 5},
+			{"title": "Score #2", "regexp": "^s2$", "max": 5, "round": 0}
+		]
+	}`)
+
+	exp.detailRows = [][]string{{"", "8", "4.4"}}
+	exp.detailValues = []map[string]float64{{}}
+
+	exp.evaluateAggregates()
+
+	want := []string{"9", "5", "4"}
+	for i, cell := range exp.detailRows[0] {
+		if cell != want[i] {
+			t.Fatalf("expect row %v, got %v", want, exp.detailRows[0])
+		}
+	}
+}
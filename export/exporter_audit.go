@@ -0,0 +1,47 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// auditCell records where a cell of the detail rows comes from: the result
+// file, the original key, the matched rule and the raw and computed value.
+// Cells are recorded in the order of evaluation, so when several result files
+// write into the same cell, the last one is the value in the detail rows.
+
+type auditCell struct {
+	Row    map[string]string `json:"row"`
+	Column string            `json:"column"`
+	File   string            `json:"file"`
+	Key    string            `json:"key"`
+	Rule   auditRule         `json:"rule"`
+	Raw    interface{}       `json:"raw"`
+	Value  string            `json:"value"`
+
+	rowIndex int
+}
+
+type auditRule struct {
+	Regexp string         `json:"regexp"`
+	Meta   string         `json:"meta,omitempty"`
+	Type   exportRuleType `json:"type"`
+}
+
+func (e *exporter) exportAuditCellsJSON(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to create audit file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "\t")
+
+	if err := encoder.Encode(e.auditCells); err != nil {
+		return fmt.Errorf("fail to write audit cells: %w", err)
+	}
+
+	return nil
+}
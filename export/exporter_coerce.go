@@ -0,0 +1,86 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Coercions convert a result value into the type expected by the rule type
+// before the evaluation, instead of failing on a type mismatch. They are
+// enabled per exporter by `coerce`, while `options` of a rule maps the value
+// or the description of a select option into its numeric value.
+
+type exportCoercion string
+
+var (
+	exportCoercionStringToNumber exportCoercion = "string_to_number"
+	exportCoercionStringToBool   exportCoercion = "string_to_bool"
+	exportCoercionNumberToBool   exportCoercion = "number_to_bool"
+	exportCoercionToString       exportCoercion = "to_string"
+)
+
+var ErrInvalidExportCoercion = errors.New("invalid export coercion")
+
+func (c exportCoercion) validate() error {
+	switch c {
+	case exportCoercionStringToNumber, exportCoercionStringToBool, exportCoercionNumberToBool, exportCoercionToString:
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", c, ErrInvalidExportCoercion)
+}
+
+func (e *exporter) hasCoercion(c exportCoercion) bool {
+	for _, coercion := range e.Coerce {
+		if coercion == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// coerce returns the value converted for the rule type if possible, or the
+// original value otherwise, so that the type check reports the mismatch
+func (e *exporter) coerce(rule *exportRule, v interface{}) interface{} {
+	switch rule.Type {
+	case exportRuleTypePlainText, exportRuleTypeDeadline:
+		if _, ok := v.(string); !ok && v != nil && e.hasCoercion(exportCoercionToString) {
+			return fmt.Sprintf("%v", v)
+		}
+
+	case exportRuleTypeValuableBoolean:
+		switch value := v.(type) {
+		case float64:
+			if e.hasCoercion(exportCoercionNumberToBool) {
+				return value != 0
+			}
+		case string:
+			if e.hasCoercion(exportCoercionStringToBool) {
+				if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+					return b
+				}
+			}
+		}
+
+	case exportRuleTypeValuableComplete, exportRuleTypeVaulablePartial, exportRuleTypeThreshold:
+		value, ok := v.(string)
+		if !ok {
+			return v
+		}
+
+		if number, ok := rule.Options[value]; ok {
+			return number
+		}
+
+		if e.hasCoercion(exportCoercionStringToNumber) {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				return number
+			}
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// exportColumnRef refers to a title by its index, its id or its name. Since
+// the index shifts when inserting a new title, prefer the id or the name.
+type exportColumnRef struct {
+	name  string
+	index int
+}
+
+var (
+	ErrInvalidExportColumnRef = errors.New("invalid export column reference, expect index, id or title")
+	ErrDuplicateExportTitleId = errors.New("duplicate export title id")
+)
+
+func (r *exportColumnRef) UnmarshalJSON(b []byte) error {
+	r.index = -1
+
+	if err := json.Unmarshal(b, &r.index); err == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(b, &r.name); err == nil && r.name != "" {
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", string(b), ErrInvalidExportColumnRef)
+}
+
+// parseColumnRef parses a column reference given in the command line flags
+func parseColumnRef(s string) *exportColumnRef {
+	if index, err := strconv.Atoi(s); err == nil {
+		return &exportColumnRef{index: index}
+	}
+
+	return &exportColumnRef{name: s, index: -1}
+}
+
+func (r *exportColumnRef) String() string {
+	if r.name != "" {
+		return r.name
+	}
+
+	return strconv.Itoa(r.index)
+}
+
+// resolve finds the title by the id first, then by the name
+func (r *exportColumnRef) resolve(titles []*exportTitle) (*exportTitle, error) {
+	if r.name == "" {
+		if r.index < 0 || r.index >= len(titles) {
+			return nil, fmt.Errorf("column %d out of range: %w", r.index, ErrUnknownExportTitle)
+		}
+
+		return titles[r.index], nil
+	}
+
+	for _, title := range titles {
+		if title.Id != "" && title.Id == r.name {
+			r.index = title.index
+			return title, nil
+		}
+	}
+
+	for _, title := range titles {
+		if title.Title == r.name {
+			r.index = title.index
+			return title, nil
+		}
+	}
+
+	return nil, fmt.Errorf("column %s: %w", r.name, ErrUnknownExportTitle)
+}
+
+func resolveColumnRefs(refs []*exportColumnRef, titles []*exportTitle) error {
+	for _, ref := range refs {
+		if _, err := ref.resolve(titles); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deadline rules compare a timestamp, usually the metadata `createdAt` with
+// `"meta": "createdAt"`, against the configured deadline and evaluate to the
+// applied penalty. A percentage penalty is written as `-20%` and a fixed
+// deduction as `-5`, so the penalty column is readable in the exported file
+// and can be applied to aggregate titles with `penalties`.
+
+type exportDeadline struct {
+	// At is the global deadline in RFC3339
+	At string `json:"at"`
+	// GroupKey is the result key whose value selects the deadline in `Groups`
+	GroupKey string            `json:"groupKey"`
+	Groups   map[string]string `json:"groups"`
+	// Cutoff is the time in RFC3339 after which the penalty is 100%
+	Cutoff  string            `json:"cutoff"`
+	Penalty exportPenaltyType `json:"penalty"`
+	Value   float64           `json:"value"`
+
+	at     time.Time
+	groups map[string]time.Time
+	cutoff time.Time
+}
+
+var (
+	ErrInvalidExportPenaltyType = errors.New("invalid export penalty type")
+	ErrNoExportDeadline         = errors.New("no export deadline")
+	ErrNoGroupDeadline          = errors.New("no deadline of the group and no global deadline")
+)
+
+type exportPenaltyType string
+
+var (
+	// exportPenaltyTypePercentPerDay deducts `Value` percent for each day late
+	exportPenaltyTypePercentPerDay exportPenaltyType = "percent_per_day"
+	// exportPenaltyTypeFixed deducts `Value` points once late
+	exportPenaltyTypeFixed exportPenaltyType = "fixed"
+	// exportPenaltyTypeZero deducts 100 percent once late
+	exportPenaltyTypeZero exportPenaltyType = "zero"
+)
+
+const noPenalty = "0"
+
+func (d *exportDeadline) compile() error {
+	switch d.Penalty {
+	case exportPenaltyTypePercentPerDay, exportPenaltyTypeFixed, exportPenaltyTypeZero:
+	default:
+		return ErrInvalidExportPenaltyType
+	}
+
+	if d.At != "" {
+		at, err := time.Parse(time.RFC3339, d.At)
+		if err != nil {
+			return fmt.Errorf("fail to parse deadline %s: %w", d.At, err)
+		}
+
+		d.at = at
+	}
+
+	d.groups = make(map[string]time.Time)
+	for group, deadline := range d.Groups {
+		at, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			return fmt.Errorf("fail to parse deadline %s of group %s: %w", deadline, group, err)
+		}
+
+		d.groups[group] = at
+	}
+
+	if d.Cutoff != "" {
+		cutoff, err := time.Parse(time.RFC3339, d.Cutoff)
+		if err != nil {
+			return fmt.Errorf("fail to parse cutoff %s: %w", d.Cutoff, err)
+		}
+
+		d.cutoff = cutoff
+	}
+
+	return nil
+}
+
+// getDeadline returns the deadline of the group, or the global deadline if the
+// group has no deadline
+func (d *exportDeadline) getDeadline(result map[string]interface{}) (time.Time, error) {
+	if d.GroupKey != "" {
+		value, ok := result[d.GroupKey]
+		group := fmt.Sprintf("%v", value)
+		if at, found := d.groups[group]; ok && found {
+			return at, nil
+		}
+
+		if d.at.IsZero() {
+			return time.Time{}, fmt.Errorf("%s=%s: %w", d.GroupKey, group, ErrNoGroupDeadline)
+		}
+	}
+
+	if d.at.IsZero() {
+		return time.Time{}, ErrNoExportDeadline
+	}
+
+	return d.at, nil
+}
+
+// evaluate returns the penalty applied to a result created at `createdAt`
+func (d *exportDeadline) evaluate(createdAt time.Time, result map[string]interface{}) (string, error) {
+	deadline, err := d.getDeadline(result)
+	if err != nil {
+		return "", err
+	}
+
+	if !d.cutoff.IsZero() && createdAt.After(d.cutoff) {
+		return "-100%", nil
+	}
+
+	if !createdAt.After(deadline) {
+		return noPenalty, nil
+	}
+
+	switch d.Penalty {
+	case exportPenaltyTypePercentPerDay:
+		days := math.Ceil(createdAt.Sub(deadline).Hours() / 24)
+		percent := math.Min(days*d.Value, 100)
+
+		return "-" + strconv.FormatFloat(percent, 'f', -1, 64) + "%", nil
+	case exportPenaltyTypeFixed:
+		return "-" + strconv.FormatFloat(d.Value, 'f', -1, 64), nil
+	case exportPenaltyTypeZero:
+		return "-100%", nil
+	}
+
+	return "", ErrInvalidExportPenaltyType
+}
+
+// applyPenalty applies a penalty cell evaluated by a deadline rule on value
+func applyPenalty(value float64, penalty string) float64 {
+	if strings.HasSuffix(penalty, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(penalty, "%"), 64)
+		if err != nil {
+			return value
+		}
+
+		return value * (100 + percent) / 100
+	}
+
+	deduction, err := strconv.ParseFloat(penalty, 64)
+	if err != nil {
+		return value
+	}
+
+	return math.Max(value+deduction, 0)
+}
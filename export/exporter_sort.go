@@ -1,18 +1,185 @@
 package export
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 // Implementation of the sort interface of exporter.
 // Since there is no way to identify number and string
 // just simplify use the isNumeric func to identify
-// number and string.
+// number and string, unless the collation of the
+// sort column is specified.
+
+type exportSortColumn struct {
+	Title     *exportColumnRef `json:"title"`
+	Order     exportSortOrder  `json:"order"`
+	Collation exportCollation  `json:"collation"`
+
+	index int
+}
+
+var (
+	ErrInvalidExportSortOrder = errors.New("invalid export sort order")
+	ErrInvalidExportCollation = errors.New("invalid export collation")
+)
+
+type exportSortOrder string
+
+var (
+	exportSortOrderAsc  exportSortOrder = "asc"
+	exportSortOrderDesc exportSortOrder = "desc"
+)
+
+type exportCollation string
+
+var (
+	// exportCollationAuto compares numbers as numbers and others byte by byte
+	exportCollationAuto exportCollation = "auto"
+	// exportCollationNatural compares digit sequences as numbers, so that
+	// `g2s2` comes before `g2s10`
+	exportCollationNatural exportCollation = "natural"
+	// exportCollationLexical compares byte by byte
+	exportCollationLexical exportCollation = "lexical"
+)
+
+// UnmarshalJSON accepts the index, id or name of the title, or the sort column
+// object
+func (c *exportSortColumn) UnmarshalJSON(b []byte) error {
+	var title exportColumnRef
+	if err := title.UnmarshalJSON(b); err == nil {
+		c.Title = &title
+		return nil
+	}
+
+	type sortColumn exportSortColumn
+	return json.Unmarshal(b, (*sortColumn)(c))
+}
+
+func (c *exportSortColumn) compile(titles []*exportTitle) error {
+	if c.Order == "" {
+		c.Order = exportSortOrderAsc
+	}
+
+	if c.Collation == "" {
+		c.Collation = exportCollationAuto
+	}
+
+	switch c.Order {
+	case exportSortOrderAsc, exportSortOrderDesc:
+	default:
+		return fmt.Errorf("%s: %w", c.Order, ErrInvalidExportSortOrder)
+	}
+
+	switch c.Collation {
+	case exportCollationAuto, exportCollationNatural, exportCollationLexical:
+	default:
+		return fmt.Errorf("%s: %w", c.Collation, ErrInvalidExportCollation)
+	}
+
+	if c.Title == nil {
+		return fmt.Errorf("sort column without title: %w", ErrInvalidExportColumnRef)
+	}
+
+	title, err := c.Title.resolve(titles)
+	if err != nil {
+		return err
+	}
+
+	c.index = title.index
+
+	return nil
+}
+
+func (c *exportSortColumn) compare(a, b string) int {
+	var result int
+
+	switch c.Collation {
+	case exportCollationNatural:
+		result = compareNatural(a, b)
+	case exportCollationLexical:
+		result = strings.Compare(a, b)
+	default:
+		result = compareAuto(a, b)
+	}
+
+	if c.Order == exportSortOrderDesc {
+		return -result
+	}
+
+	return result
+}
+
+func compareAuto(a, b string) int {
+	aValue, aErr := strconv.ParseFloat(a, 64)
+	bValue, bErr := strconv.ParseFloat(b, 64)
+
+	if aErr == nil && bErr == nil && aValue != bValue {
+		if aValue < bValue {
+			return -1
+		}
+
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares digit sequences by their numeric value and other
+// characters byte by byte
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aDigits, bDigits := leadingDigits(a), leadingDigits(b)
+
+		if aDigits == "" || bDigits == "" {
+			if a[0] != b[0] {
+				if a[0] < b[0] {
+					return -1
+				}
+
+				return 1
+			}
+
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		aNumber, bNumber := strings.TrimLeft(aDigits, "0"), strings.TrimLeft(bDigits, "0")
+		if len(aNumber) != len(bNumber) {
+			if len(aNumber) < len(bNumber) {
+				return -1
+			}
+
+			return 1
+		}
+
+		if result := strings.Compare(aNumber, bNumber); result != 0 {
+			return result
+		}
+
+		a, b = a[len(aDigits):], b[len(bDigits):]
+	}
+
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return s[:i]
+		}
+	}
+
+	return s
+}
 
 type detailRowSlice struct {
 	detailRows  [][]string
-	sortColumns []int
+	sortColumns []*exportSortColumn
 }
 
 var _ sort.Interface = (*detailRowSlice)(nil)
@@ -27,17 +194,11 @@ func (s *detailRowSlice) Swap(i, j int) {
 
 func (s *detailRowSlice) Less(i, j int) bool {
 	for _, sortColumn := range s.sortColumns {
-		iValue, iErr := strconv.ParseFloat(s.detailRows[i][sortColumn], 64)
-		jValue, jErr := strconv.ParseFloat(s.detailRows[j][sortColumn], 64)
-
-		if iErr == nil && jErr == nil && iValue != jValue {
-			return iValue < jValue
-		}
-
-		if s.detailRows[i][sortColumn] != s.detailRows[j][sortColumn] {
-			return s.detailRows[i][sortColumn] < s.detailRows[j][sortColumn]
+		result := sortColumn.compare(s.detailRows[i][sortColumn.index], s.detailRows[j][sortColumn.index])
+		if result != 0 {
+			return result < 0
 		}
 	}
 
-	return true
+	return false
 }
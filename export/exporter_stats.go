@@ -0,0 +1,239 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pterm/pterm"
+)
+
+// Statistics of every numeric title column of the detail rows, except the key
+// columns. A column is numeric when all of its non-empty cells are numbers.
+// The statistics are rounded by the `round` of the column, or to 2 decimal
+// places.
+
+// defaultStatRound is the decimal places of the statistics of the columns
+// without `round`
+const defaultStatRound = 2
+
+type columnStats struct {
+	title     string
+	round     *int
+	count     int
+	mean      float64
+	median    float64
+	stddev    float64
+	min       float64
+	max       float64
+	histogram []*histogramBin
+}
+
+type histogramBin struct {
+	lower float64
+	upper float64
+	count int
+	// last bin includes its upper bound
+	last bool
+}
+
+var summaryTitleRow = []string{"Title", "Count", "Mean", "Median", "Std Dev", "Min", "Max", "Histogram"}
+
+func (e *exporter) evaluateStats(bins int) []*columnStats {
+	stats := make([]*columnStats, 0)
+
+	for _, title := range e.Titles {
+		if e.isKeyColumn(title.index) {
+			continue
+		}
+
+		values, ok := e.getColumnValues(title.index)
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		s := newColumnStats(title.Title, values, bins)
+		s.round = title.Round
+		stats = append(stats, s)
+	}
+
+	e.summaryRows = make([][]string, 0, len(stats))
+	for _, s := range stats {
+		e.summaryRows = append(e.summaryRows, s.row())
+	}
+
+	return stats
+}
+
+// isKeyColumn returns true if the title is a key column, such as the student
+// ids, which is not a score even if it is numeric
+func (e *exporter) isKeyColumn(column int) bool {
+	for _, keyColumn := range e.KeyColumns {
+		if keyColumn.index == column {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *exporter) getColumnValues(column int) ([]float64, bool) {
+	values := make([]float64, 0, len(e.detailRows))
+
+	for _, row := range e.detailRows {
+		if row[column] == "" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(row[column], 64)
+		if err != nil {
+			return nil, false
+		}
+
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+func newColumnStats(title string, values []float64, bins int) *columnStats {
+	sort.Float64s(values)
+
+	s := &columnStats{
+		title: title,
+		count: len(values),
+		min:   values[0],
+		max:   values[len(values)-1],
+	}
+
+	for _, value := range values {
+		s.mean += value
+	}
+	s.mean /= float64(s.count)
+
+	if s.count%2 == 1 {
+		s.median = values[s.count/2]
+	} else {
+		s.median = (values[s.count/2-1] + values[s.count/2]) / 2
+	}
+
+	for _, value := range values {
+		s.stddev += (value - s.mean) * (value - s.mean)
+	}
+	s.stddev = math.Sqrt(s.stddev / float64(s.count))
+
+	if s.min == s.max {
+		bins = 1
+	}
+
+	width := (s.max - s.min) / float64(bins)
+	for i := 0; i < bins; i++ {
+		s.histogram = append(s.histogram, &histogramBin{
+			lower: s.min + width*float64(i),
+			upper: s.min + width*float64(i+1),
+			last:  i == bins-1,
+		})
+	}
+
+	for _, value := range values {
+		i := bins - 1
+		if width > 0 {
+			i = int(math.Min((value-s.min)/width, float64(bins-1)))
+		}
+
+		s.histogram[i].count++
+	}
+
+	return s
+}
+
+func (s *columnStats) row() []string {
+	histogram := ""
+	for i, bin := range s.histogram {
+		if i > 0 {
+			histogram += " "
+		}
+
+		histogram += fmt.Sprintf("%s:%d", bin.label(), bin.count)
+	}
+
+	return []string{
+		s.title,
+		strconv.Itoa(s.count),
+		s.format(s.mean),
+		s.format(s.median),
+		s.format(s.stddev),
+		s.format(s.min),
+		s.format(s.max),
+		histogram,
+	}
+}
+
+func (b *histogramBin) label() string {
+	if b.last {
+		return fmt.Sprintf("[%.4g,%.4g]", b.lower, b.upper)
+	}
+
+	return fmt.Sprintf("[%.4g,%.4g)", b.lower, b.upper)
+}
+
+func (s *columnStats) format(value float64) string {
+	if s.round != nil {
+		return strconv.FormatFloat(value, 'f', *s.round, 64)
+	}
+
+	scale := math.Pow(10, defaultStatRound)
+	return strconv.FormatFloat(math.Round(value*scale)/scale, 'f', -1, 64)
+}
+
+func printStats(stats []*columnStats) error {
+	data := [][]string{summaryTitleRow[:len(summaryTitleRow)-1]}
+	for _, s := range stats {
+		row := s.row()
+		data = append(data, row[:len(row)-1])
+	}
+
+	pterm.DefaultSection.Println("Statistics")
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+		return fmt.Errorf("fail to render statistics table: %w", err)
+	}
+
+	for _, s := range stats {
+		pterm.DefaultSection.WithLevel(2).Println("Histogram of " + s.title)
+
+		bars := make(pterm.Bars, 0, len(s.histogram))
+		for _, bin := range s.histogram {
+			bars = append(bars, pterm.Bar{Label: bin.label(), Value: bin.count})
+		}
+
+		if err := pterm.DefaultBarChart.WithHorizontal().WithShowValue().WithBars(bars).Render(); err != nil {
+			return fmt.Errorf("fail to render histogram of %s: %w", s.title, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *exporter) exportSummaryRowsCSV(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to create csv file: %w", err)
+	}
+	defer f.Close()
+
+	writer := csv.NewWriter(f)
+
+	if err := writer.Write(summaryTitleRow); err != nil {
+		return fmt.Errorf("fail to write header row: %w", err)
+	}
+
+	if err := writer.WriteAll(e.summaryRows); err != nil {
+		return fmt.Errorf("fail to write summary rows: %w", err)
+	}
+
+	return writer.Error()
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+)
+
+func TestEvaluateDetailIsDeterministic(t *testing.T) {
+	answers := make(map[string]*store.Answer)
+	for i := 1; i <= 9; i++ {
+		answers[fmt.Sprintf("s%d", i)] = store.NumberAnswer(float64(i))
+	}
+	r := &store.Result{Answers: answers}
+
+	var want [][]string
+	for i := 0; i < 50; i++ {
+		exp := newTestExporter(t, `{
+			"groupSize": 1,
+			"titles": [
+				{"title": "Score", "regexp": "^s\\d$"},
+				{"title": "Passed"}
+			],
+			"rules": [
+				{"regexp": "^s\\d$", "type": "valuable_complete", "for": "all", "continue": true},
+				{"regexp": "^s\\d$", "type": "threshold", "value": 5, "for": "all", "title": "Passed"}
+			]
+		}`)
+
+		detail, err := exp.evaluateDetail("result.json", r)
+		if err != nil {
+			t.Fatalf("fail to evaluate detail: %v", err)
+		}
+
+		exp.insertDetailRows("result.json", detail)
+		exp.evaluateAggregates()
+
+		if want == nil {
+			want = exp.detailRows
+			if want[0][0] != "9" || want[0][1] != "true" {
+				t.Fatalf("expect the cells of the last key s9, got %v", want)
+			}
+		}
+
+		if !reflect.DeepEqual(exp.detailRows, want) {
+			t.Fatalf("expect the same rows %v on every export, got %v", want, exp.detailRows)
+		}
+	}
+}
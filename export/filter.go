@@ -0,0 +1,86 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+)
+
+// resultFilter filters result files by their content, including the metadata
+// createdBy, createdAt and version stored by the record command, and
+// arbitrary key=value predicates.
+
+type resultFilter struct {
+	// meta matches the metadata except the creators, as `--created-by` takes
+	// several creators
+	meta      *store.Filter
+	createdBy []string
+	where     map[string]string
+}
+
+var ErrInvalidWherePredicate = errors.New("invalid where predicate, expect key=value")
+
+func newResultFilter() (*resultFilter, error) {
+	filter := &resultFilter{
+		meta:      &store.Filter{Version: filterVersion},
+		createdBy: filterCreatedBy,
+		where:     make(map[string]string),
+	}
+
+	var err error
+	if filterCreatedAfter != "" {
+		if filter.meta.After, err = store.ParseFilterTime(filterCreatedAfter, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if filterCreatedBefore != "" {
+		if filter.meta.Before, err = store.ParseFilterTime(filterCreatedBefore, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, predicate := range filterWhere {
+		kv := strings.SplitN(predicate, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%s: %w", predicate, ErrInvalidWherePredicate)
+		}
+
+		filter.where[kv[0]] = kv[1]
+	}
+
+	return filter, nil
+}
+
+// match matches the metadata of the result, and the predicates on the
+// flattened result
+func (f *resultFilter) match(r *store.Result, result map[string]interface{}) bool {
+	if len(f.createdBy) > 0 {
+		matched := false
+		for _, name := range f.createdBy {
+			if r.Meta.CreatedBy == name {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if !f.meta.Match(r) {
+		return false
+	}
+
+	for k, v := range f.where {
+		value, ok := result[k]
+		if !ok || fmt.Sprintf("%v", value) != v {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,255 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+)
+
+// resultFile is a result file loaded from the store directories, or from an
+// archive or a SQLite store inside them, in which case the name is
+// `<archive>:<entry>` or `<database>:<id>`.
+type resultFile struct {
+	name string
+	data []byte
+}
+
+type resultFileLoader struct {
+	filter    *regexp.Regexp
+	recursive bool
+
+	files []*resultFile
+}
+
+func loadResultFiles() ([]*resultFile, error) {
+	re, err := regexp.Compile(filterRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("fail to compile regexp %s: %w", filterRegexp, err)
+	}
+
+	l := &resultFileLoader{
+		filter:    re,
+		recursive: recursive,
+		files:     make([]*resultFile, 0),
+	}
+
+	for _, storeDir := range storeDirs {
+		paths, err := filepath.Glob(storeDir)
+		if err != nil {
+			return nil, fmt.Errorf("fail to match store pattern %s: %w", storeDir, err)
+		}
+
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("fail to find store %s: %w", storeDir, os.ErrNotExist)
+		}
+
+		for _, p := range paths {
+			if err := l.load(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return l.files, nil
+}
+
+func (l *resultFileLoader) load(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return fmt.Errorf("fail to stat %s: %w", p, err)
+	}
+
+	if !info.IsDir() {
+		return l.loadFile(p)
+	}
+
+	if !l.recursive {
+		files, err := os.ReadDir(p)
+		if err != nil {
+			return fmt.Errorf("fail to read the store directory %s: %w", p, err)
+		}
+
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+
+			if err := l.loadFile(filepath.Join(p, file.Name())); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return filepath.WalkDir(p, func(fileName string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("fail to walk the store directory %s: %w", p, err)
+		}
+
+		if d.IsDir() {
+			// skip the hidden directories such as the history of the store
+			if fileName != p && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		return l.loadFile(fileName)
+	})
+}
+
+// loadFile loads a result file or all result files in an archive or in a
+// SQLite store
+func (l *resultFileLoader) loadFile(fileName string) error {
+	switch {
+	case store.IsSQLite(fileName):
+		return l.loadSQLite(fileName)
+	case strings.HasSuffix(fileName, ".zip"):
+		return l.loadZip(fileName)
+	case strings.HasSuffix(fileName, ".tar.gz"), strings.HasSuffix(fileName, ".tgz"):
+		return l.loadTarGz(fileName)
+	}
+
+	if !l.filter.MatchString(filepath.Base(fileName)) {
+		return nil
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to read result file %s: %w", fileName, err)
+	}
+
+	l.files = append(l.files, &resultFile{name: fileName, data: data})
+
+	return nil
+}
+
+// loadSQLite loads all results in a SQLite store as the result files, the
+// filter does not apply to the ids
+func (l *resultFileLoader) loadSQLite(fileName string) error {
+	pterm.Debug.Println("Loading results from sqlite store:", fileName)
+
+	s, err := store.Open(fileName, resultCipher)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// the skipped results are only warned with `--lenient` or `--watch`, as
+	// the result files failed to export
+	entries, err := s.List(nil)
+	if lenient || watch {
+		err = store.WarnSkipped(err)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to list results in sqlite store %s: %w", fileName, err)
+	}
+
+	for _, entry := range entries {
+		data, err := store.Encode(entry.Result)
+		if err != nil {
+			return fmt.Errorf("fail to marshal result %s in sqlite store %s: %w", entry.Id, fileName, err)
+		}
+
+		l.files = append(l.files, &resultFile{name: fileName + ":" + entry.Id, data: data})
+	}
+
+	return nil
+}
+
+func (l *resultFileLoader) loadZip(fileName string) error {
+	pterm.Debug.Println("Loading result files from zip archive:", fileName)
+
+	r, err := zip.OpenReader(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to open zip archive %s: %w", fileName, err)
+	}
+	defer r.Close()
+
+	for _, entry := range r.File {
+		if entry.FileInfo().IsDir() || isHiddenEntry(entry.Name) || !l.filter.MatchString(path.Base(entry.Name)) {
+			continue
+		}
+
+		f, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("fail to open %s in zip archive %s: %w", entry.Name, fileName, err)
+		}
+
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("fail to read %s in zip archive %s: %w", entry.Name, fileName, err)
+		}
+
+		l.files = append(l.files, &resultFile{name: fileName + ":" + entry.Name, data: data})
+	}
+
+	return nil
+}
+
+func (l *resultFileLoader) loadTarGz(fileName string) error {
+	pterm.Debug.Println("Loading result files from tar.gz archive:", fileName)
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to open tar.gz archive %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("fail to decompress tar.gz archive %s: %w", fileName, err)
+	}
+	defer gz.Close()
+
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("fail to read tar.gz archive %s: %w", fileName, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || isHiddenEntry(header.Name) || !l.filter.MatchString(path.Base(header.Name)) {
+			continue
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("fail to read %s in tar.gz archive %s: %w", header.Name, fileName, err)
+		}
+
+		l.files = append(l.files, &resultFile{name: fileName + ":" + header.Name, data: data})
+	}
+
+	return nil
+}
+
+// isHiddenEntry returns true if the archive entry is in a hidden directory,
+// such as the history or the trash of an archived store, which are skipped as
+// the hidden directories of the store directories
+func isHiddenEntry(name string) bool {
+	for _, dir := range strings.Split(path.Dir(path.Clean(name)), "/") {
+		if dir != "." && dir != ".." && strings.HasPrefix(dir, ".") {
+			return true
+		}
+	}
+
+	return false
+}
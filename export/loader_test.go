@@ -0,0 +1,111 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+var archivedStoreFiles = []string{
+	"store/example_1.json",
+	"store/.history/example_1.json/1640995200000000000.json",
+	"store/.trash/example_2.json",
+	"store/class/example_3.json",
+}
+
+func newTestLoader() *resultFileLoader {
+	return &resultFileLoader{
+		filter: regexp.MustCompile(`.*\.json`),
+		files:  make([]*resultFile, 0),
+	}
+}
+
+func loadedNames(l *resultFileLoader, archive string) []string {
+	names := make([]string, 0, len(l.files))
+	for _, f := range l.files {
+		names = append(names, f.name[len(archive)+1:])
+	}
+
+	return names
+}
+
+func TestLoadZipSkipsHiddenDirectories(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "store.zip")
+
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := zip.NewWriter(f)
+	for _, name := range archivedStoreFiles {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := entry.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l := newTestLoader()
+	if err := l.loadZip(archive); err != nil {
+		t.Fatalf("fail to load zip archive: %v", err)
+	}
+
+	want := []string{"store/example_1.json", "store/class/example_3.json"}
+	if got := loadedNames(l, archive); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+}
+
+func TestLoadTarGzSkipsHiddenDirectories(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "store.tar.gz")
+
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := gzip.NewWriter(f)
+	w := tar.NewWriter(gz)
+	for _, name := range archivedStoreFiles {
+		header := &tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0600, Size: 2}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte("{}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l := newTestLoader()
+	if err := l.loadTarGz(archive); err != nil {
+		t.Fatalf("fail to load tar.gz archive: %v", err)
+	}
+
+	want := []string{"./store/example_1.json", "./store/class/example_3.json"}
+	if got := loadedNames(l, archive); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+}
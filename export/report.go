@@ -0,0 +1,37 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// exportReport records the result files failed to export in lenient mode,
+// and the unsigned or modified result files exported with `--verify-key`.
+type exportReport struct {
+	Exported   int                `json:"exported"`
+	Failed     []*resultFileError `json:"failed"`
+	Unverified []*resultFileError `json:"unverified"`
+}
+
+type resultFileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+func (r *exportReport) exportJSON(fileName string) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("fail to create report file: %w", err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "\t")
+
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("fail to write report: %w", err)
+	}
+
+	return nil
+}
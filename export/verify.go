@@ -0,0 +1,60 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+)
+
+// resultVerifier verifies the signatures of the result files with the TA key,
+// the unsigned or modified result files are flagged, or rejected if
+// `--reject-unverified` is set
+type resultVerifier struct {
+	key    []byte
+	reject bool
+
+	unverified []*resultFileError
+}
+
+func newResultVerifier() (*resultVerifier, error) {
+	v := &resultVerifier{reject: rejectUnverified, unverified: make([]*resultFileError, 0)}
+
+	if verifyKeyFile == "" {
+		if rejectUnverified {
+			return nil, fmt.Errorf("--reject-unverified: %w", store.ErrEmptySignKey)
+		}
+
+		return v, nil
+	}
+
+	key, err := store.LoadSignKey(verifyKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	v.key = key
+
+	return v, nil
+}
+
+// verify returns an error if the result is unverified and rejected, a nil
+// verifier or key verifies nothing
+func (v *resultVerifier) verify(fileName string, r *store.Result) error {
+	if v == nil || v.key == nil {
+		return nil
+	}
+
+	err := store.Verify(r, v.key)
+	if err == nil {
+		return nil
+	}
+
+	if v.reject {
+		return fmt.Errorf("fail to verify result file %s: %w", fileName, err)
+	}
+
+	pterm.Warning.Printf("Unverified result file %s: %v\n", fileName, err)
+	v.unverified = append(v.unverified, &resultFileError{File: fileName, Error: err.Error()})
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// runWatch checks the stores every `interval`, and re-exports when result
+// files are added, modified or removed. The progress of the roster is shown
+// in a live table.
+func runWatch(name string, exp *exporter, cache *exportCache) {
+	roster, err := loadRoster(rosterFile)
+	if err != nil {
+		pterm.Fatal.Println("Fail to load roster:", err)
+	}
+
+	var rosterTitle *exportTitle
+	if rosterColumn != "" {
+		if rosterTitle, err = parseColumnRef(rosterColumn).resolve(exp.Titles); err != nil {
+			pterm.Fatal.Println("Fail to find roster column:", err)
+		}
+	} else if len(exp.KeyColumns) > 0 {
+		rosterTitle = exp.Titles[exp.KeyColumns[0].index]
+	}
+
+	if len(roster) > 0 && rosterTitle == nil {
+		pterm.Fatal.Println("Expect --roster-column or key columns to match the roster")
+	}
+
+	area, err := pterm.DefaultArea.Start()
+	if err != nil {
+		pterm.Fatal.Println("Fail to start live area:", err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	fingerprint := ""
+	resultFilesCount := 0
+	for {
+		// the output of the export is suppressed not to scramble the live
+		// area, the errors are shown in the progress instead
+		pterm.DisableOutput()
+		resultFiles, err := loadResultFiles()
+		if err != nil {
+			fingerprint = ""
+			pterm.EnableOutput()
+			area.Update(renderProgress(exp, roster, rosterTitle, resultFilesCount, nil, fmt.Errorf("fail to load result files: %w", err)))
+		} else if newFingerprint := getFingerprint(resultFiles); newFingerprint != fingerprint {
+			resultFilesCount = len(resultFiles)
+
+			report, err := runExport(name, exp, cache, resultFiles)
+			pterm.EnableOutput()
+			area.Update(renderProgress(exp, roster, rosterTitle, resultFilesCount, report, err))
+
+			// a failed export is retried on the next check
+			if err == nil {
+				fingerprint = newFingerprint
+			}
+		}
+		pterm.EnableOutput()
+
+		select {
+		case <-interrupt:
+			area.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getFingerprint identifies the names and contents of the result files
+func getFingerprint(resultFiles []*resultFile) string {
+	var b strings.Builder
+
+	for _, f := range resultFiles {
+		b.WriteString(f.name)
+		b.WriteString(":")
+		b.WriteString(f.hash())
+		b.WriteString(";")
+	}
+
+	return b.String()
+}
+
+// loadRoster reads the student ids in the first column of each line
+func loadRoster(fileName string) ([]string, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open roster file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("fail to read roster file %s: %w", fileName, err)
+	}
+
+	roster := make([]string, 0, len(records))
+	for _, record := range records {
+		if len(record) > 0 && strings.TrimSpace(record[0]) != "" {
+			roster = append(roster, strings.TrimSpace(record[0]))
+		}
+	}
+
+	return roster, nil
+}
+
+// renderProgress shows the progress of the roster, and the result files failed
+// to export or the error of the last export
+func renderProgress(exp *exporter, roster []string, rosterTitle *exportTitle, resultFilesCount int, report *exportReport, exportErr error) string {
+	data := [][]string{
+		{"Updated At", time.Now().Format("15:04:05")},
+		{"Result Files", strconv.Itoa(resultFilesCount)},
+		{"Exported Rows", strconv.Itoa(len(exp.detailRows))},
+	}
+
+	if len(roster) > 0 {
+		graded := make(map[string]bool)
+		for _, row := range exp.detailRows {
+			graded[row[rosterTitle.index]] = true
+		}
+
+		pending := make([]string, 0)
+		for _, id := range roster {
+			if !graded[id] {
+				pending = append(pending, id)
+			}
+		}
+		sort.Strings(pending)
+
+		gradedCount := len(roster) - len(pending)
+		data = append(data,
+			[]string{"Graded", fmt.Sprintf("%d / %d (%.1f%%)", gradedCount, len(roster), float64(gradedCount)*100/float64(len(roster)))},
+			[]string{"Pending", strings.Join(pending, ", ")},
+		)
+	}
+
+	if report != nil {
+		data = append(data, []string{"Failed", strconv.Itoa(len(report.Failed))})
+		for _, failed := range report.Failed {
+			data = append(data, []string{"", failed.Error})
+		}
+
+		if len(report.Unverified) > 0 {
+			data = append(data, []string{"Unverified", strconv.Itoa(len(report.Unverified))})
+		}
+	}
+
+	if exportErr != nil {
+		data = append(data, []string{"Error", exportErr.Error()})
+	}
+
+	table, err := pterm.DefaultTable.WithData(data).Srender()
+	if err != nil {
+		return fmt.Sprintf("fail to render progress table: %v", err)
+	}
+
+	return pterm.DefaultSection.Sprint("Export progress") + "\n" + table
+}
@@ -0,0 +1,111 @@
+package record
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+)
+
+type duplicateAction string
+
+const (
+	duplicateActionReplace  duplicateAction = "Replace the previous result"
+	duplicateActionKeepBoth duplicateAction = "Keep both results"
+	duplicateActionCancel   duplicateAction = "Cancel"
+)
+
+// resolveDuplicate finds the stored results of the same student by the
+// identity prompts, shows the latest one and asks how to store the new
+// result. It returns the id of the latest result to replace, and keeps both if
+// there is no duplicate.
+func (o *recorder) resolveDuplicate(s store.Store, name string, r *store.Result) (duplicateAction, string, error) {
+	key := o.studentKey(r.Answers)
+	if key == "" {
+		return duplicateActionKeepBoth, "", nil
+	}
+
+	entries, err := s.List(&store.Filter{Name: name})
+	if err = store.WarnSkipped(err); err != nil {
+		return "", "", fmt.Errorf("fail to list stored results: %w", err)
+	}
+
+	// the student key is computed from the answers, as the results recorded
+	// before the identity prompts have no student in the metadata
+	duplicates := make([]*store.Entry, 0)
+	for _, entry := range entries {
+		if o.studentKey(entry.Result.Answers) == key {
+			duplicates = append(duplicates, entry)
+		}
+	}
+
+	if len(duplicates) == 0 {
+		return duplicateActionKeepBoth, "", nil
+	}
+
+	sort.SliceStable(duplicates, func(i, j int) bool {
+		return createdAt(duplicates[i].Result).Before(createdAt(duplicates[j].Result))
+	})
+	latest := duplicates[len(duplicates)-1]
+
+	pterm.Println("")
+	pterm.Warning.Printf("%d results of %s are stored, the latest one is %s\n", len(duplicates), key, latest.Id)
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(duplicateTable(latest.Result, r)).Render(); err != nil {
+		return "", "", fmt.Errorf("fail to render duplicate table: %w", err)
+	}
+
+	var action string
+	prompt := &survey.Select{
+		Message: "Do you want to replace the previous result?",
+		Options: []string{string(duplicateActionReplace), string(duplicateActionKeepBoth), string(duplicateActionCancel)},
+	}
+	if err := survey.AskOne(prompt, &action); err != nil {
+		return "", "", err
+	}
+
+	return duplicateAction(action), latest.Id, nil
+}
+
+// duplicateTable compares the metadata and the answers of the previous and the
+// new result, the options are shown by the label
+func duplicateTable(previous *store.Result, r *store.Result) [][]string {
+	data := [][]string{
+		{"Key", "Previous", "New"},
+		{"Created At", previous.Meta.CreatedAt, r.Meta.CreatedAt},
+		{"Created By", previous.Meta.CreatedBy, r.Meta.CreatedBy},
+		{"TA", previous.Meta.TA, r.Meta.TA},
+	}
+
+	keys := r.Keys()
+	for _, k := range previous.Keys() {
+		if _, ok := r.Answers[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		data = append(data, []string{k, answerLabel(previous.Answers[k]), answerLabel(r.Answers[k])})
+	}
+
+	return data
+}
+
+func answerLabel(answer *store.Answer) string {
+	if answer == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%v", answer.Field(store.AnswerFieldLabel))
+}
+
+// createdAt returns the creation time of the result, the zero time if invalid
+func createdAt(r *store.Result) time.Time {
+	t, _ := time.Parse(time.RFC3339, r.Meta.CreatedAt)
+
+	return t
+}
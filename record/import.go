@@ -0,0 +1,353 @@
+package record
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/justin0u0/NTHU-OS-Demo/store"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importMappings        []string
+	importCreatedBy       string
+	importCreatedAtColumn string
+	importTimeLayout      string
+	importYes             bool
+)
+
+var (
+	ErrInvalidImportMapping = errors.New("invalid import mapping, expect column=key")
+	ErrUnknownImportKey     = errors.New("unknown import key")
+	ErrInvalidImportValue   = errors.New("invalid import value")
+)
+
+func NewImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "import [path] [csv]",
+		Short:   "Import rows of a csv file, such as Google Forms responses, into the store",
+		Example: "demo import example responses.csv --map \"Student ID=input\"",
+		Args:    cobra.ExactArgs(2),
+		Run:     runImport,
+	}
+
+	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory or the SQLite database (.db) to store the results")
+	cmd.Flags().StringArrayVarP(&importMappings, "map", "m", nil, "Map a csv column into a record key (column=key), columns named by a record key are mapped by default")
+	cmd.Flags().StringVar(&importCreatedBy, "created-by", "", "The creator of the imported results, defaults to the current user")
+	cmd.Flags().StringVar(&ta, "ta", "", "The TA id stored in the imported results, defaults to the current username")
+	cmd.Flags().StringVar(&importCreatedAtColumn, "created-at-column", "", "The csv column of the creation time, defaults to now")
+	cmd.Flags().StringVar(&importTimeLayout, "time-layout", time.RFC3339, "The Go time layout of the creation time column")
+	cmd.Flags().BoolVarP(&importYes, "yes", "y", false, "Store the imported results without confirmation")
+	cmd.Flags().BoolVar(&storeGit, "store-git", false, "Commit each imported result file into the git repository of the store directory")
+	cmd.Flags().StringVar(&signKeyFile, "sign-key", "", "The TA key file to sign the imported results, for export to detect modified results")
+	cmd.Flags().StringVar(&encryptKey, "encrypt-key", "", "The key file to encrypt the stored results")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Ask for the passphrase to encrypt the stored results")
+
+	return cmd
+}
+
+// importKey is a record key which a csv column can be imported into
+type importKey struct {
+	key    string
+	survey *surveyObj
+}
+
+func runImport(_ *cobra.Command, args []string) {
+	rec, err := loadRecorder(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to load record file:", err)
+	}
+
+	signKey, err := loadSignKey()
+	if err != nil {
+		pterm.Fatal.Println("Fail to load sign key:", err)
+	}
+
+	keys, err := rec.getImportKeys()
+	if err != nil {
+		pterm.Fatal.Println("Fail to get record keys:", err)
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		pterm.Fatal.Println("Fail to open csv file:", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		pterm.Fatal.Println("Fail to read csv file:", err)
+	}
+
+	if len(records) < 2 {
+		pterm.Fatal.Println("Expect a header row and at least one row in the csv file")
+	}
+
+	columns, err := getImportColumns(records[0], keys)
+	if err != nil {
+		pterm.Fatal.Println("Fail to map csv columns:", err)
+	}
+
+	createdAtColumn := -1
+	if importCreatedAtColumn != "" {
+		for i, header := range records[0] {
+			if header == importCreatedAtColumn {
+				createdAtColumn = i
+			}
+		}
+
+		if createdAtColumn < 0 {
+			pterm.Fatal.Println("Fail to find the created at column:", importCreatedAtColumn)
+		}
+	}
+
+	createdBy, taId := getCurrentUser()
+	if importCreatedBy != "" {
+		createdBy = importCreatedBy
+	}
+
+	results := make([]*store.Result, 0, len(records)-1)
+	for i, row := range records[1:] {
+		answers := make(map[string]*store.Answer)
+
+		for column, key := range columns {
+			if column >= len(row) || strings.TrimSpace(row[column]) == "" {
+				continue
+			}
+
+			value, err := key.survey.convertImportValue(strings.TrimSpace(row[column]))
+			if err != nil {
+				pterm.Fatal.Printf("Fail to import row %d column %s: %v\n", i+2, records[0][column], err)
+			}
+
+			answers[key.key] = value
+		}
+
+		createdAt := time.Now()
+		if createdAtColumn >= 0 {
+			if createdAt, err = time.Parse(importTimeLayout, row[createdAtColumn]); err != nil {
+				pterm.Fatal.Printf("Fail to parse created at of row %d: %v\n", i+2, err)
+			}
+		}
+
+		r := rec.newResult(args[0], answers)
+		r.Meta.CreatedAt = createdAt.Format(time.RFC3339)
+		r.Meta.CreatedBy = createdBy
+		r.Meta.TA = taId
+
+		if signKey != nil {
+			if err := store.Sign(r, signKey); err != nil {
+				pterm.Fatal.Println("Fail to sign result:", err)
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	preview, err := json.Marshal(results[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to marshal result:", err)
+	}
+
+	pterm.Println("")
+	pterm.Success.Println("first result: ", string(preview))
+	pterm.Println("")
+
+	shouldStore := importYes
+	if !shouldStore {
+		message := fmt.Sprintf("Do you want to store the %d results?", len(results))
+		if err := survey.AskOne(&survey.Confirm{Message: message}, &shouldStore); err != nil {
+			pterm.Fatal.Println("Fail to confirm should store:", err)
+		}
+	}
+
+	if !shouldStore {
+		return
+	}
+
+	s, err := openStore()
+	if err != nil {
+		pterm.Fatal.Println("Fail to open store:", err)
+	}
+	defer s.Close()
+
+	for _, result := range results {
+		_, err := s.Insert(result)
+		if err = store.WarnNotCommitted(err); err != nil {
+			pterm.Fatal.Println("Fail to store result:", err)
+		}
+	}
+
+	pterm.Println("")
+	pterm.Success.Printf("%d results imported.\n", len(results))
+}
+
+// getImportKeys returns all keys the survey prompts can store, including the
+// keys of each loop option, an error is returned if 2 prompts store the same
+// key
+func (o *recorder) getImportKeys() (map[string]*importKey, error) {
+	keys := make(map[string]*importKey)
+	add := func(key string, s *surveyObj) error {
+		if _, ok := keys[key]; ok {
+			return fmt.Errorf("%s: %w", key, ErrDuplicateRecordKey)
+		}
+
+		keys[key] = &importKey{key: key, survey: s}
+		return nil
+	}
+
+	for i := range o.Processes {
+		p := &o.Processes[i]
+		if p.Type != recordTypeSurvey {
+			continue
+		}
+
+		s := &p.Survey
+		switch s.Type {
+		case surveyPromptTypeLoopSelectInput, surveyPromptTypeLoopSelectSelect:
+			for _, option := range s.LoopOptions {
+				subKey, ok := option.Value.(string)
+				if !ok {
+					continue
+				}
+
+				key := s.Key + "." + subKey
+				if err := add(key, s.loopInnerSurvey(key)); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if err := add(s.Key, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+// getImportColumns maps the csv columns into the record keys
+func getImportColumns(header []string, keys map[string]*importKey) (map[int]*importKey, error) {
+	columns := make(map[int]*importKey)
+
+	for i, column := range header {
+		if key, ok := keys[column]; ok {
+			columns[i] = key
+		}
+	}
+
+	for _, mapping := range importMappings {
+		kv := strings.SplitN(mapping, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%s: %w", mapping, ErrInvalidImportMapping)
+		}
+
+		key, ok := keys[kv[1]]
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", kv[1], ErrUnknownImportKey)
+		}
+
+		found := false
+		for i, column := range header {
+			if column == kv[0] {
+				columns[i] = key
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("fail to find csv column %s", kv[0])
+		}
+	}
+
+	for i, column := range header {
+		if _, ok := columns[i]; !ok && column != importCreatedAtColumn {
+			pterm.Warning.Println("No match record key, skipping column:", column)
+		}
+	}
+
+	return columns, nil
+}
+
+// convertImportValue converts a csv cell into the answer stored by the prompt
+func (o *surveyObj) convertImportValue(cell string) (*store.Answer, error) {
+	switch o.Type {
+	case surveyPromptTypeInput:
+		switch o.ValueType {
+		case surveyPromptValueTypeNumber:
+			value, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, fmt.Errorf("expect number %s: %w", cell, ErrInvalidImportValue)
+			}
+
+			return store.NumberAnswer(value), nil
+		case surveyPromptValueTypeBool:
+			return parseImportBool(cell)
+		case surveyPromptValueTypeString:
+			return store.StringAnswer(cell), nil
+		}
+
+		return nil, ErrInvalidSurveyValueType
+
+	case surveyPromptTypeConfirm:
+		return parseImportBool(cell)
+
+	case surveyPromptTypeSelect:
+		return o.findImportOption(cell)
+
+	case surveyPromptTypeMultiSelect:
+		// checkbox responses list the chosen options separated by commas
+		options := make([]*store.Answer, 0)
+		for _, c := range strings.Split(cell, ",") {
+			if c = strings.TrimSpace(c); c == "" {
+				continue
+			}
+
+			option, err := o.findImportOption(c)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, option)
+		}
+
+		return store.OptionsAnswer(options), nil
+	}
+
+	return nil, ErrInvalidSurveyType
+}
+
+// findImportOption returns the answer of the option matching the description
+// or the value
+func (o *surveyObj) findImportOption(cell string) (*store.Answer, error) {
+	for i, option := range o.Options {
+		if option.Desc == cell || fmt.Sprintf("%v", option.Value) == cell {
+			return o.optionAnswer(i), nil
+		}
+	}
+
+	return nil, fmt.Errorf("expect option of %s, got %s: %w", o.Key, cell, ErrInvalidImportValue)
+}
+
+func parseImportBool(cell string) (*store.Answer, error) {
+	switch strings.ToLower(cell) {
+	case "y", "yes":
+		return store.BoolAnswer(true), nil
+	case "n", "no":
+		return store.BoolAnswer(false), nil
+	}
+
+	value, err := strconv.ParseBool(cell)
+	if err != nil {
+		return nil, fmt.Errorf("expect bool %s: %w", cell, ErrInvalidImportValue)
+	}
+
+	return store.BoolAnswer(value), nil
+}
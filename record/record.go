@@ -2,14 +2,18 @@ package record
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/user"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/justin0u0/NTHU-OS-Demo/store"
 	"github.com/justin0u0/NTHU-OS-Demo/version"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
@@ -18,7 +22,15 @@ import (
 //go:embed assets
 var recordFS embed.FS
 
-var storeDir string
+var (
+	storeDir    string
+	ta          string
+	editId      string
+	storeGit    bool
+	signKeyFile string
+	encryptKey  string
+	passphrase  bool
+)
 
 func NewRecordCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -29,51 +41,70 @@ func NewRecordCommand() *cobra.Command {
 		Run:     run,
 	}
 
-	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory to store the result file")
+	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory or the SQLite database (.db) to store the result")
+	cmd.Flags().StringVar(&ta, "ta", "", "The TA id stored in the result, defaults to the current username")
+	cmd.Flags().StringVar(&editId, "edit", "", "The id of a stored result to record again, the replaced result is kept in the history")
+	cmd.Flags().BoolVar(&storeGit, "store-git", false, "Commit each stored or edited result file into the git repository of the store directory")
+	cmd.Flags().StringVar(&signKeyFile, "sign-key", "", "The TA key file to sign the result, for export to detect modified results")
+	cmd.Flags().StringVar(&encryptKey, "encrypt-key", "", "The key file to encrypt the stored result")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Ask for the passphrase to encrypt the stored result")
 
 	return cmd
 }
 
-var (
-	storeKeyCreatedAt = "createdAt"
-	storeKeyCreatedBy = "createdBy"
-	storeKeyVersion   = "version"
-)
-
 func run(_ *cobra.Command, args []string) {
-	fileName := "assets/" + args[0] + ".json"
-	storeKeyCreatedAt = args[0] + "." + storeKeyCreatedAt
-	storeKeyCreatedBy = args[0] + "." + storeKeyCreatedBy
-	storeKeyVersion = args[0] + "." + storeKeyVersion
+	rec, err := loadRecorder(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to load record file:", err)
+	}
 
-	pterm.Debug.Println("Running record from file:", fileName)
+	signKey, err := loadSignKey()
+	if err != nil {
+		pterm.Fatal.Println("Fail to load sign key:", err)
+	}
 
-	f, err := recordFS.ReadFile(fileName)
+	s, err := openStore()
 	if err != nil {
-		pterm.Fatal.Println("Fail to read record file:", err)
+		pterm.Fatal.Println("Fail to open store:", err)
 	}
+	defer s.Close()
 
-	var rec recorder
-	if err := json.NewDecoder(bytes.NewReader(f)).Decode(&rec.Processes); err != nil {
-		pterm.Fatal.Println("Fail to parse record object:", err)
+	var edited *store.Result
+	if editId != "" {
+		if edited, err = s.Get(editId); err != nil {
+			pterm.Fatal.Println("Fail to get the edited result:", err)
+		}
+
+		if edited.Config.Name != args[0] {
+			pterm.Fatal.Printf("Fail to edit result %s recorded by %s\n", editId, edited.Config.Name)
+		}
 	}
 
+	startedAt := time.Now()
+
 	if err := rec.Execute(); err != nil {
 		pterm.Fatal.Println("Fail to execute record process:", err)
 	}
 
 	// add additional informations
-	rec.store[storeKeyCreatedAt] = time.Now().Format(time.RFC3339)
-	if user, err := user.Current(); err != nil {
-		pterm.Error.Println("Fail to get current username:", err)
-		rec.store[storeKeyCreatedBy] = "unknown"
-	} else {
-		rec.store[storeKeyCreatedBy] = user.Name
+	r := rec.newResult(args[0], rec.answers)
+	r.Meta.CreatedAt = time.Now().Format(time.RFC3339)
+	r.Meta.CreatedBy, r.Meta.TA = getCurrentUser()
+	r.Meta.Duration = time.Since(startedAt).Round(time.Second).String()
+
+	// an edit keeps the creation time, which the deadline rules depend on
+	if edited != nil {
+		r.Meta.CreatedAt = edited.Meta.CreatedAt
+	}
+
+	if signKey != nil {
+		if err := store.Sign(r, signKey); err != nil {
+			pterm.Fatal.Println("Fail to sign result:", err)
+		}
 	}
-	rec.store[storeKeyVersion] = version.Version
 
 	// marshal result into json bytes
-	result, err := json.Marshal(rec.store)
+	result, err := json.Marshal(r)
 	if err != nil {
 		pterm.Fatal.Println("Fail to marshal result store:", err)
 	}
@@ -82,23 +113,208 @@ func run(_ *cobra.Command, args []string) {
 	pterm.Success.Println("result: ", string(result))
 	pterm.Println("")
 
-	var store bool
-	if err := survey.AskOne(&survey.Confirm{Message: "Do you want to store the result?"}, &store); err != nil {
+	var shouldStore bool
+	if err := survey.AskOne(&survey.Confirm{Message: "Do you want to store the result?"}, &shouldStore); err != nil {
 		pterm.Fatal.Println("Fail to confirm should store:", err)
 	}
 
-	if store {
-		if err := os.MkdirAll(storeDir, 0755); err != nil {
-			pterm.Fatal.Println("Fail to mkdir store directory:", err)
+	if shouldStore {
+		if edited != nil {
+			if err := store.WarnNotCommitted(s.Update(editId, r)); err != nil {
+				pterm.Fatal.Println("Fail to update result:", err)
+			}
+		} else {
+			storeResult(rec, s, args[0], r)
 		}
+	}
+
+	pterm.Println("")
+	pterm.Success.Println("done.")
+}
 
-		fileName := storeDir + "/" + args[0] + "_" + strconv.FormatInt(time.Now().Unix(), 10) + ".json"
+// storeResult inserts the new result, or replaces the stored result of the
+// same student if chosen. The result is inserted if the duplicate check fails,
+// so that the recorded answers are never lost.
+func storeResult(rec *recorder, s store.Store, name string, r *store.Result) {
+	action, duplicateId, err := rec.resolveDuplicate(s, name, r)
+	if err != nil {
+		pterm.Warning.Println("Fail to check duplicate results, storing as a new result:", err)
+		action = duplicateActionKeepBoth
+	}
+
+	switch action {
+	case duplicateActionReplace:
+		if err := store.WarnNotCommitted(s.Update(duplicateId, r)); err != nil {
+			pterm.Fatal.Println("Fail to replace result:", err)
+		}
 
-		if err := os.WriteFile(fileName, result, 0644); err != nil {
-			pterm.Fatal.Println("Fail to store result to file:", err)
+		pterm.Info.Println("Replaced result:", duplicateId)
+	case duplicateActionKeepBoth:
+		id, err := s.Insert(r)
+		if err = store.WarnNotCommitted(err); err != nil {
+			pterm.Fatal.Println("Fail to store result:", err)
 		}
+
+		pterm.Info.Println("Stored result:", id)
+	default:
+		pterm.Info.Println("Cancelled, the result is not stored.")
 	}
+}
 
-	pterm.Println("")
-	pterm.Success.Println("done.")
+// openStore opens the store directory in git if `--store-git` is set, the
+// results are encrypted if `--encrypt-key` or `--passphrase` is set
+func openStore() (store.Store, error) {
+	c, err := store.LoadCipher(encryptKey, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("fail to load encryption key: %w", err)
+	}
+
+	if storeGit {
+		return store.OpenGit(storeDir, c)
+	}
+
+	return store.Open(storeDir, c)
+}
+
+// loadSignKey returns the TA key of `--sign-key`, or nil if not set
+func loadSignKey() ([]byte, error) {
+	if signKeyFile == "" {
+		return nil, nil
+	}
+
+	return store.LoadSignKey(signKeyFile)
+}
+
+func loadRecorder(name string) (*recorder, error) {
+	fileName := "assets/" + name + ".json"
+
+	pterm.Debug.Println("Running record from file:", fileName)
+
+	f, err := recordFS.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read record file %s: %w", fileName, err)
+	}
+
+	var rec recorder
+	if err := json.NewDecoder(bytes.NewReader(f)).Decode(&rec.Processes); err != nil {
+		return nil, fmt.Errorf("fail to parse record object %s: %w", fileName, err)
+	}
+
+	// a prompt storing the key of a previous prompt would replace its answer
+	if _, err := rec.getImportKeys(); err != nil {
+		return nil, fmt.Errorf("fail to validate record object %s: %w", fileName, err)
+	}
+
+	hash := sha256.Sum256(f)
+	rec.hash = hex.EncodeToString(hash[:])
+
+	return &rec, nil
+}
+
+// newResult wraps the answers into the result envelope, with the versions of
+// the CLI, the result file format and the record config
+func (o *recorder) newResult(name string, answers map[string]*store.Answer) *store.Result {
+	r := &store.Result{
+		Meta: store.Meta{
+			TA:            ta,
+			Version:       version.Version,
+			SchemaVersion: store.SchemaVersion,
+		},
+		Answers: answers,
+		Config: store.Config{
+			Name:   name,
+			Hash:   o.hash,
+			Labels: o.labels(answers),
+		},
+	}
+
+	r.Meta.Student = o.studentKey(answers)
+
+	if hostname, err := os.Hostname(); err != nil {
+		pterm.Error.Println("Fail to get hostname:", err)
+	} else {
+		r.Meta.Hostname = hostname
+	}
+
+	return r
+}
+
+// studentKey joins the answers of the identity prompts in the order of the
+// record config, it is empty if no identity prompt is answered
+func (o *recorder) studentKey(answers map[string]*store.Answer) string {
+	keys := make([]string, 0)
+	for _, p := range o.Processes {
+		if p.Type != recordTypeSurvey || !p.Survey.Identity {
+			continue
+		}
+
+		if answer, ok := answers[p.Survey.Key]; ok {
+			keys = append(keys, fmt.Sprintf("%v", answer.Field(store.AnswerFieldValue)))
+		}
+	}
+
+	return strings.Join(keys, "-")
+}
+
+// LoadLabels returns the prompt messages of the answered keys in the record
+// config `name`, for `store show`
+func LoadLabels(name string, answers map[string]*store.Answer) (map[string]string, error) {
+	rec, err := loadRecorder(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return rec.labels(answers), nil
+}
+
+// labels returns the prompt messages of the answered keys, the loop keys are
+// labeled with the desc of the loop option as well
+func (o *recorder) labels(answers map[string]*store.Answer) map[string]string {
+	labels := make(map[string]string)
+	for _, p := range o.Processes {
+		if p.Type != recordTypeSurvey {
+			continue
+		}
+
+		s := p.Survey
+		switch s.Type {
+		case surveyPromptTypeLoopSelectInput, surveyPromptTypeLoopSelectSelect:
+			for _, option := range s.LoopOptions {
+				subKey, ok := option.Value.(string)
+				if !ok {
+					continue
+				}
+
+				if _, ok := answers[s.Key+"."+subKey]; ok {
+					labels[s.Key+"."+subKey] = fmt.Sprintf("%s (%s)", s.Message, option.Desc)
+				}
+			}
+		default:
+			if _, ok := answers[s.Key]; ok {
+				labels[s.Key] = s.Message
+			}
+		}
+	}
+
+	return labels
+}
+
+// getCurrentUser returns the display name and the TA id of the current user,
+// the TA id is the `--ta` flag if set
+func getCurrentUser() (string, string) {
+	user, err := user.Current()
+	if err != nil {
+		pterm.Error.Println("Fail to get current username:", err)
+		if ta == "" {
+			return "unknown", "unknown"
+		}
+
+		return "unknown", ta
+	}
+
+	if ta == "" {
+		return user.Name, user.Username
+	}
+
+	return user.Name, ta
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/justin0u0/NTHU-OS-Demo/store"
 	"github.com/pterm/pterm"
 )
 
@@ -15,11 +16,14 @@ type recorder struct {
 		Survey surveyObj  `json:"survey"`
 	}
 
-	store map[string]interface{}
+	answers map[string]*store.Answer
+	// hash is the sha256 of the record config
+	hash string
 }
 
 var (
-	ErrInvalidDemoType = errors.New("invalid record type")
+	ErrInvalidDemoType    = errors.New("invalid record type")
+	ErrDuplicateRecordKey = errors.New("duplicate record key")
 )
 
 type recordType string
@@ -31,8 +35,8 @@ const (
 )
 
 func (o *recorder) Execute() error {
-	if o.store == nil {
-		o.store = make(map[string]interface{})
+	if o.answers == nil {
+		o.answers = make(map[string]*store.Answer)
 	}
 
 	for _, p := range o.Processes {
@@ -46,7 +50,7 @@ func (o *recorder) Execute() error {
 		case recordTypeImgcat:
 			err = p.Imgcat.Execute()
 		case recordTypeSurvey:
-			err = p.Survey.Execute(o.store)
+			err = p.Survey.Execute(o.answers)
 		default:
 			err = ErrInvalidDemoType
 		}
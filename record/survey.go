@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/justin0u0/NTHU-OS-Demo/store"
 	"github.com/pterm/pterm"
 )
 
@@ -13,9 +14,11 @@ type surveyObj struct {
 	Type        surveyPromptType        `json:"type"`
 	Key         string                  `json:"key"`         // for all types
 	ValueType   surveyPromptValueType   `json:"valueType"`   // for type input
-	Message     string                  `json:"message"`     // for type input, confirm, select
-	Options     []surveyPromptOptionObj `json:"options"`     // for type select
+	Message     string                  `json:"message"`     // for type input, confirm, select, multiSelect
+	Options     []surveyPromptOptionObj `json:"options"`     // for type select, multiSelect
 	LoopOptions []surveyPromptOptionObj `json:"loopOptions"` // for type loopSelectInput, loopSelectSelect
+	// Identity marks the prompt identifying the student, such as the student id
+	Identity bool `json:"identity"`
 }
 
 var (
@@ -35,6 +38,7 @@ var (
 	surveyPromptTypeInput            surveyPromptType = "input"
 	surveyPromptTypeConfirm          surveyPromptType = "confirm"
 	surveyPromptTypeSelect           surveyPromptType = "select"
+	surveyPromptTypeMultiSelect      surveyPromptType = "multiSelect"
 	surveyPromptTypeLoopSelectInput  surveyPromptType = "loopSelectInput"
 	surveyPromptTypeLoopSelectSelect surveyPromptType = "loopSelectSelect"
 )
@@ -47,15 +51,21 @@ var (
 	surveyPromptValueTypeString surveyPromptValueType = "string"
 )
 
-func (o *surveyObj) Execute(store map[string]interface{}) error {
+// Execute asks the prompt and stores the answer as a plain typed value, so
+// that the answer does not alias the local variables or the options
+func (o *surveyObj) Execute(answers map[string]*store.Answer) error {
 	var (
 		intValue    int
+		intValues   []int
 		numberValue float64
 		boolValue   bool
 		stringValue string
 	)
 
-	var prompt survey.Prompt
+	var (
+		prompt   survey.Prompt
+		response interface{}
+	)
 	switch o.Type {
 	case surveyPromptTypeInput:
 		prompt = &survey.Input{Message: o.Message}
@@ -63,11 +73,11 @@ func (o *surveyObj) Execute(store map[string]interface{}) error {
 		// type surveyPromptTypeInput store ValueType value
 		switch o.ValueType {
 		case surveyPromptValueTypeNumber:
-			store[o.Key] = &numberValue
+			response = &numberValue
 		case surveyPromptValueTypeBool:
-			store[o.Key] = &boolValue
+			response = &boolValue
 		case surveyPromptValueTypeString:
-			store[o.Key] = &stringValue
+			response = &stringValue
 		default:
 			return ErrInvalidSurveyValueType
 		}
@@ -76,41 +86,90 @@ func (o *surveyObj) Execute(store map[string]interface{}) error {
 		prompt = &survey.Confirm{Message: o.Message}
 
 		// type surveyPromptTypeConfirm store boolean value
-		store[o.Key] = &boolValue
+		response = &boolValue
 
 	case surveyPromptTypeSelect:
-		options := make([]string, 0, len(o.Options))
-		for _, option := range o.Options {
-			options = append(options, option.Desc)
-		}
-
-		prompt = &survey.Select{Message: o.Message, Options: options, PageSize: 10}
+		prompt = &survey.Select{Message: o.Message, Options: o.optionDescs(), PageSize: 10}
 
 		// type surveyPromptTypeSelect store the chosen option index into `intValue`
-		store[o.Key] = &intValue
+		response = &intValue
+
+	case surveyPromptTypeMultiSelect:
+		prompt = &survey.MultiSelect{Message: o.Message, Options: o.optionDescs(), PageSize: 10}
+
+		// type surveyPromptTypeMultiSelect store the chosen option indexes into `intValues`
+		response = &intValues
 
 	case surveyPromptTypeLoopSelectSelect, surveyPromptTypeLoopSelectInput:
-		return o.handleLoopTypePrompt(store)
+		return o.handleLoopTypePrompt(answers)
 
 	default:
 		return ErrInvalidSurveyType
 	}
 
-	if err := survey.AskOne(prompt, store[o.Key]); err != nil {
+	if err := survey.AskOne(prompt, response); err != nil {
 		return err
 	}
 
-	switch o.Type {
-	case surveyPromptTypeSelect:
-		store[o.Key] = &o.Options[intValue].Value
+	switch response.(type) {
+	case *float64:
+		answers[o.Key] = store.NumberAnswer(numberValue)
+	case *bool:
+		answers[o.Key] = store.BoolAnswer(boolValue)
+	case *string:
+		answers[o.Key] = store.StringAnswer(stringValue)
+	case *int:
+		answers[o.Key] = o.optionAnswer(intValue)
+	case *[]int:
+		options := make([]*store.Answer, 0, len(intValues))
+		for _, i := range intValues {
+			options = append(options, o.optionAnswer(i))
+		}
+		answers[o.Key] = store.OptionsAnswer(options)
 	}
 
 	return nil
 }
 
+func (o *surveyObj) optionDescs() []string {
+	descs := make([]string, 0, len(o.Options))
+	for _, option := range o.Options {
+		descs = append(descs, option.Desc)
+	}
+
+	return descs
+}
+
+// optionAnswer returns the answer of the option with its value, label and index
+func (o *surveyObj) optionAnswer(i int) *store.Answer {
+	return store.OptionAnswer(i, o.Options[i].Value, o.Options[i].Desc)
+}
+
 var loopTypePromptFinishTag = "*FINISH*"
 
-func (o *surveyObj) handleLoopTypePrompt(store map[string]interface{}) error {
+// loopInnerSurvey returns the prompt of a loop option storing into `key`
+func (o *surveyObj) loopInnerSurvey(key string) *surveyObj {
+	switch o.Type {
+	case surveyPromptTypeLoopSelectInput:
+		return &surveyObj{
+			Type:      surveyPromptTypeInput,
+			Key:       key,
+			ValueType: o.ValueType,
+			Message:   o.Message,
+		}
+	case surveyPromptTypeLoopSelectSelect:
+		return &surveyObj{
+			Type:    surveyPromptTypeSelect,
+			Key:     key,
+			Message: o.Message,
+			Options: o.Options,
+		}
+	}
+
+	return nil
+}
+
+func (o *surveyObj) handleLoopTypePrompt(answers map[string]*store.Answer) error {
 	options := make([]string, 0, len(o.LoopOptions))
 	for _, option := range o.LoopOptions {
 		options = append(options, option.Desc)
@@ -138,27 +197,9 @@ func (o *surveyObj) handleLoopTypePrompt(store map[string]interface{}) error {
 			return ErrInvalidSurveyLoopOptionsValueType
 		}
 
-		key := o.Key + "." + subKey
-
-		var innerSurvey *surveyObj
-		switch o.Type {
-		case surveyPromptTypeLoopSelectInput:
-			innerSurvey = &surveyObj{
-				Type:      surveyPromptTypeInput,
-				Key:       key,
-				ValueType: o.ValueType,
-				Message:   o.Message,
-			}
-		case surveyPromptTypeLoopSelectSelect:
-			innerSurvey = &surveyObj{
-				Type:    surveyPromptTypeSelect,
-				Key:     key,
-				Message: o.Message,
-				Options: o.Options,
-			}
-		}
+		innerSurvey := o.loopInnerSurvey(o.Key + "." + subKey)
 
-		if err := innerSurvey.Execute(store); err != nil {
+		if err := innerSurvey.Execute(answers); err != nil {
 			return err
 		}
 
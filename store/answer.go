@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Answer is a recorded answer with its type. Answers of select prompts keep
+// the index and the label of the chosen option together with the option
+// value, answers of multiselect prompts keep each chosen option.
+type Answer struct {
+	Type  AnswerType  `json:"type"`
+	Value interface{} `json:"value"`
+	// Label is the description of the chosen option, only for type option
+	Label string `json:"label,omitempty"`
+	// Index is the index of the chosen option, only for type option
+	Index *int `json:"index,omitempty"`
+	// Options is the chosen options, only for type options
+	Options []*Answer `json:"options,omitempty"`
+}
+
+type AnswerType string
+
+const (
+	AnswerTypeNumber  AnswerType = "number"
+	AnswerTypeBool    AnswerType = "bool"
+	AnswerTypeString  AnswerType = "string"
+	AnswerTypeOption  AnswerType = "option"
+	AnswerTypeOptions AnswerType = "options"
+)
+
+// AnswerField is the field of an answer to output
+type AnswerField string
+
+const (
+	AnswerFieldValue AnswerField = "value"
+	AnswerFieldLabel AnswerField = "label"
+	AnswerFieldIndex AnswerField = "index"
+)
+
+var ErrInvalidAnswerField = errors.New("invalid answer field")
+
+// Validate returns an error if the field is neither empty nor a known field
+func (f AnswerField) Validate() error {
+	switch f {
+	case "", AnswerFieldValue, AnswerFieldLabel, AnswerFieldIndex:
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", f, ErrInvalidAnswerField)
+}
+
+// optionsSeparator joins the fields of the chosen options of type options
+const optionsSeparator = ", "
+
+func NumberAnswer(value float64) *Answer {
+	return &Answer{Type: AnswerTypeNumber, Value: value}
+}
+
+func BoolAnswer(value bool) *Answer {
+	return &Answer{Type: AnswerTypeBool, Value: value}
+}
+
+func StringAnswer(value string) *Answer {
+	return &Answer{Type: AnswerTypeString, Value: value}
+}
+
+func OptionAnswer(index int, value interface{}, label string) *Answer {
+	return &Answer{Type: AnswerTypeOption, Value: value, Label: label, Index: &index}
+}
+
+// OptionsAnswer returns the answer of the chosen options, the value is the
+// list of the option values
+func OptionsAnswer(options []*Answer) *Answer {
+	values := make([]interface{}, 0, len(options))
+	for _, option := range options {
+		values = append(values, option.Value)
+	}
+
+	return &Answer{Type: AnswerTypeOptions, Value: values, Options: options}
+}
+
+// Field returns the field of the answer. Answers other than the options, and
+// options from result files without the field, fall back to the value. The
+// fields of the chosen options of type options are joined into a string.
+func (a *Answer) Field(field AnswerField) interface{} {
+	switch a.Type {
+	case AnswerTypeOption:
+		switch field {
+		case AnswerFieldLabel:
+			if a.Label != "" {
+				return a.Label
+			}
+		case AnswerFieldIndex:
+			if a.Index != nil {
+				return float64(*a.Index)
+			}
+		}
+
+	case AnswerTypeOptions:
+		fields := make([]string, 0, len(a.Options))
+		for _, option := range a.Options {
+			fields = append(fields, fmt.Sprintf("%v", option.Field(field)))
+		}
+
+		return strings.Join(fields, optionsSeparator)
+	}
+
+	return a.Value
+}
+
+// inferAnswer returns the answer of a value decoded from the result files
+// before schema version 3, which have no type
+func inferAnswer(value interface{}) *Answer {
+	switch v := value.(type) {
+	case float64:
+		return NumberAnswer(v)
+	case bool:
+		return BoolAnswer(v)
+	case string:
+		return StringAnswer(v)
+	}
+
+	return &Answer{Value: value}
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// Store is the storage of the results, which is either a directory of result
+// files or a SQLite database
+type Store interface {
+	// Insert stores a new result and returns the id of the result
+	Insert(r *Result) (string, error)
+	// Update replaces the result of the id, the replaced result is kept in the
+	// history of the result
+	Update(id string, r *Result) error
+	Get(id string) (*Result, error)
+	// List returns the results matching the filter ordered by id, a nil filter
+	// matches all results. The results failed to read or decode are skipped
+	// and returned as `*SkippedResultsError` together with the other results.
+	List(filter *Filter) ([]*Entry, error)
+	// History returns the replaced results of the id, the oldest first
+	History(id string) ([]*Revision, error)
+	// Remove moves the result of the id into the trash of the store
+	Remove(id string) error
+	Close() error
+}
+
+// Entry is a stored result with its id
+type Entry struct {
+	Id     string
+	Result *Result
+}
+
+// Revision is a replaced result with the time it was replaced
+type Revision struct {
+	ReplacedAt time.Time
+	Result     *Result
+}
+
+var ErrResultNotFound = errors.New("result not found")
+
+// SkippedResultsError is the results skipped by `List`, such as a non-result
+// JSON file or an encrypted result without the key
+type SkippedResultsError struct {
+	Skipped []*SkippedResult
+}
+
+type SkippedResult struct {
+	Id  string
+	Err error
+}
+
+func (e *SkippedResultsError) Error() string {
+	return fmt.Sprintf("%d results skipped, the first is %s: %v", len(e.Skipped), e.Skipped[0].Id, e.Skipped[0].Err)
+}
+
+// skip records the skipped result
+func (e *SkippedResultsError) skip(id string, err error) {
+	e.Skipped = append(e.Skipped, &SkippedResult{Id: id, Err: err})
+}
+
+// err returns nil if no result is skipped
+func (e *SkippedResultsError) err() error {
+	if len(e.Skipped) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// WarnSkipped warns the results skipped by `List` and returns nil, other
+// errors are returned as is
+func WarnSkipped(err error) error {
+	var skipped *SkippedResultsError
+	if !errors.As(err, &skipped) {
+		return err
+	}
+
+	for _, result := range skipped.Skipped {
+		pterm.Warning.Println("Skipped result:", result.Err)
+	}
+
+	return nil
+}
+
+// sqliteExts is the file extensions opened as a SQLite database
+var sqliteExts = []string{".db", ".sqlite", ".sqlite3"}
+
+// IsSQLite returns true if the path is opened as a SQLite database
+func IsSQLite(path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range sqliteExts {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Open opens the SQLite database if the path has a SQLite extension such as
+// `.db`, otherwise the directory of result files. The stored results are
+// encrypted by the cipher, a nil cipher stores them in cleartext.
+func Open(path string, c *Cipher) (Store, error) {
+	if IsSQLite(path) {
+		return openSQLite(path, c)
+	}
+
+	return &dirStore{dir: path, cipher: c}, nil
+}
+
+// FilterDateLayout is the layout of the dates accepted by `ParseFilterTime`
+const FilterDateLayout = "2006-01-02"
+
+// ParseFilterTime parses a RFC3339 time or a date, the date is the end of the
+// day if `endOfDay` is set, so that the date itself is included in the range
+func ParseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(FilterDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("fail to parse time %s, expect RFC3339 or %s: %w", value, FilterDateLayout, err)
+	}
+
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, nil
+}
+
+// Filter matches the results by the metadata, empty fields match all results
+type Filter struct {
+	Name      string
+	CreatedBy string
+	TA        string
+	Version   string
+	Student   string
+	After     time.Time
+	Before    time.Time
+}
+
+func (f *Filter) Match(r *Result) bool {
+	if f == nil {
+		return true
+	}
+
+	if (f.Name != "" && r.Config.Name != f.Name) ||
+		(f.CreatedBy != "" && r.Meta.CreatedBy != f.CreatedBy) ||
+		(f.TA != "" && r.Meta.TA != f.TA) ||
+		(f.Version != "" && r.Meta.Version != f.Version) ||
+		(f.Student != "" && r.Meta.Student != f.Student) {
+		return false
+	}
+
+	if f.After.IsZero() && f.Before.IsZero() {
+		return true
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, r.Meta.CreatedAt)
+	if err != nil {
+		return false
+	}
+
+	return (f.After.IsZero() || !createdAt.Before(f.After)) && (f.Before.IsZero() || createdAt.Before(f.Before))
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	filterName          string
+	filterCreatedBy     string
+	filterTA            string
+	filterVersion       string
+	filterStudent       string
+	filterCreatedAfter  string
+	filterCreatedBefore string
+	showHistory         bool
+	storeGit            bool
+)
+
+func newListCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Short:   "List the results in the store",
+		Example: "demo store ls --name example --ta ta1",
+		Args:    cobra.NoArgs,
+		Run:     runList,
+	}
+
+	addStoreFlags(cmd)
+	cmd.Flags().StringVar(&filterName, "name", "", "Only list results recorded by the given record config")
+	cmd.Flags().StringVar(&filterCreatedBy, "created-by", "", "Only list results created by the given user")
+	cmd.Flags().StringVar(&filterTA, "ta", "", "Only list results of the given TA id")
+	cmd.Flags().StringVar(&filterVersion, "version", "", "Only list results recorded by the given CLI version")
+	cmd.Flags().StringVar(&filterStudent, "student", "", "Only list results of the given student")
+	cmd.Flags().StringVar(&filterCreatedAfter, "created-after", "", "Only list results created at or after the time (RFC3339 or 2006-01-02)")
+	cmd.Flags().StringVar(&filterCreatedBefore, "created-before", "", "Only list results created before the time, a date is included (RFC3339 or 2006-01-02)")
+
+	return cmd
+}
+
+func newShowCommand(loadLabels LabelLoader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "show [id]",
+		Short:   "Show a result in the store with the prompt messages of the answers",
+		Example: "demo store show example_110062000_ta1_1640995200_0123abcd.json",
+		Args:    cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			runShow(loadLabels, args)
+		},
+	}
+
+	addStoreFlags(cmd)
+	cmd.Flags().BoolVar(&showHistory, "history", false, "Also list the replaced results")
+
+	return cmd
+}
+
+func newRemoveCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "rm [id...]",
+		Short:   "Move results into the trash of the store",
+		Example: "demo store rm example_110062000_ta1_1640995200_0123abcd.json",
+		Args:    cobra.MinimumNArgs(1),
+		Run:     runRemove,
+	}
+
+	addStoreFlags(cmd)
+
+	return cmd
+}
+
+func addStoreFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory or the SQLite database (.db) of the results")
+	cmd.Flags().StringVar(&encryptKey, "encrypt-key", "", "The key file to decrypt the encrypted results")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Ask for the passphrase to decrypt the encrypted results")
+	cmd.Flags().BoolVar(&storeGit, "store-git", false, "Open the store directory in git, the history is the git log and the removed result files are committed")
+}
+
+// openStore opens the store of `--store` in git if `--store-git` is set,
+// decrypting the results with `--encrypt-key` or `--passphrase`
+func openStore() Store {
+	c, err := LoadCipher(encryptKey, passphrase)
+	if err != nil {
+		pterm.Fatal.Println("Fail to load encryption key:", err)
+	}
+
+	var s Store
+	if storeGit {
+		s, err = OpenGit(storeDir, c)
+	} else {
+		s, err = Open(storeDir, c)
+	}
+	if err != nil {
+		pterm.Fatal.Println("Fail to open store:", err)
+	}
+
+	return s
+}
+
+func runList(_ *cobra.Command, _ []string) {
+	filter := &Filter{
+		Name:      filterName,
+		CreatedBy: filterCreatedBy,
+		TA:        filterTA,
+		Version:   filterVersion,
+		Student:   filterStudent,
+	}
+
+	var err error
+	if filterCreatedAfter != "" {
+		if filter.After, err = ParseFilterTime(filterCreatedAfter, false); err != nil {
+			pterm.Fatal.Println("Fail to parse --created-after:", err)
+		}
+	}
+	if filterCreatedBefore != "" {
+		if filter.Before, err = ParseFilterTime(filterCreatedBefore, true); err != nil {
+			pterm.Fatal.Println("Fail to parse --created-before:", err)
+		}
+	}
+
+	s := openStore()
+	defer s.Close()
+
+	entries, err := s.List(filter)
+	if err = WarnSkipped(err); err != nil {
+		pterm.Fatal.Println("Fail to list results:", err)
+	}
+
+	data := [][]string{{"Id", "Student", "Config", "TA", "Created At"}}
+	for _, entry := range entries {
+		r := entry.Result
+		data = append(data, []string{entry.Id, r.Meta.Student, r.Config.Name, r.Meta.TA, r.Meta.CreatedAt})
+	}
+
+	if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render results table:", err)
+	}
+
+	pterm.Info.Printf("%d results listed.\n", len(entries))
+}
+
+func runShow(loadLabels LabelLoader, args []string) {
+	s := openStore()
+	defer s.Close()
+
+	r, err := s.Get(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to get result:", err)
+	}
+
+	pterm.DefaultSection.Println(args[0])
+
+	signed := "no"
+	if r.Signature != "" {
+		signed = "yes"
+	}
+
+	meta := [][]string{
+		{"Key", "Value"},
+		{"Config", r.Config.Name},
+		{"Student", r.Meta.Student},
+		{"Created At", r.Meta.CreatedAt},
+		{"Created By", r.Meta.CreatedBy},
+		{"TA", r.Meta.TA},
+		{"Hostname", r.Meta.Hostname},
+		{"Duration", r.Meta.Duration},
+		{"Version", r.Meta.Version},
+		{"Schema Version", fmt.Sprintf("%d", r.Meta.SchemaVersion)},
+		{"Signed", signed},
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithData(meta).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render metadata table:", err)
+	}
+
+	pterm.DefaultSection.WithLevel(2).Println("Answers")
+	if err := pterm.DefaultTable.WithHasHeader().WithData(answersTable(r, showLabels(loadLabels, r))).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render answers table:", err)
+	}
+
+	if !showHistory {
+		return
+	}
+
+	revisions, err := s.History(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to get history:", err)
+	}
+
+	pterm.DefaultSection.WithLevel(2).Println("History")
+	history := [][]string{{"Replaced At", "Created At", "TA", "Migrations"}}
+	for _, revision := range revisions {
+		history = append(history, []string{
+			revision.ReplacedAt.Format(time.RFC3339),
+			revision.Result.Meta.CreatedAt,
+			revision.Result.Meta.TA,
+			fmt.Sprintf("%v", revision.Result.Meta.Migrations),
+		})
+	}
+	if err := pterm.DefaultTable.WithHasHeader().WithData(history).Render(); err != nil {
+		pterm.Fatal.Println("Fail to render history table:", err)
+	}
+}
+
+func runRemove(_ *cobra.Command, args []string) {
+	s := openStore()
+	defer s.Close()
+
+	for _, id := range args {
+		if err := WarnNotCommitted(s.Remove(id)); err != nil {
+			pterm.Fatal.Println("Fail to remove result:", err)
+		}
+
+		pterm.Info.Println("Moved result into trash:", id)
+	}
+
+	pterm.Success.Printf("%d results removed.\n", len(args))
+}
+
+// showLabels returns the prompt messages of the record config of the result,
+// the labels stored in the result are used for the keys not in the record
+// config, such as when the record config is removed or changed
+func showLabels(loadLabels LabelLoader, r *Result) map[string]string {
+	labels := make(map[string]string)
+	for k, label := range r.Config.Labels {
+		labels[k] = label
+	}
+
+	if loadLabels == nil {
+		return labels
+	}
+
+	loaded, err := loadLabels(r.Config.Name, r.Answers)
+	if err != nil {
+		pterm.Warning.Println("Fail to load the labels of the record config, showing the stored labels:", err)
+		return labels
+	}
+
+	for k, label := range loaded {
+		labels[k] = label
+	}
+
+	return labels
+}
+
+// answersTable lists the answers with the prompt messages, the options are
+// shown by the label
+func answersTable(r *Result, labels map[string]string) [][]string {
+	data := [][]string{{"Key", "Prompt", "Answer"}}
+	for _, k := range r.Keys() {
+		data = append(data, []string{k, labels[k], fmt.Sprintf("%v", r.Answers[k].Field(AnswerFieldLabel))})
+	}
+
+	return data
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyDir is the directory in the store directory keeping the replaced
+// result files, as `.history/<id>/<unix nano>.json`
+const historyDir = ".history"
+
+// trashDir is the directory in the store directory keeping the removed result
+// files, as `.trash/<id>`
+const trashDir = ".trash"
+
+// dirStore stores each result as a file in the directory named by
+// `newResultFileName`, the id of a result is the file name. The result files
+// are encrypted if the cipher is not nil.
+type dirStore struct {
+	dir    string
+	cipher *Cipher
+}
+
+func (s *dirStore) Insert(r *Result) (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("fail to mkdir store directory: %w", err)
+	}
+
+	b, err := encodeResult(r, s.cipher)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newResultFileName(r, s.cipher != nil)
+	if err != nil {
+		return "", err
+	}
+
+	// the result files contain the student ids and the grades
+	if err := writeFileAtomic(s.path(id), b, 0600, false); err != nil {
+		return "", fmt.Errorf("fail to write result file %s: %w", id, err)
+	}
+
+	return id, nil
+}
+
+func (s *dirStore) Update(id string, r *Result) error {
+	fileName := s.path(id)
+
+	old, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to read result file %s: %w", id, err)
+	}
+
+	b, err := encodeResult(r, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	revisionDir := filepath.Join(s.dir, historyDir, id)
+	if err := os.MkdirAll(revisionDir, 0755); err != nil {
+		return fmt.Errorf("fail to mkdir history directory: %w", err)
+	}
+
+	// the replaced result is encrypted as well when a cleartext store is
+	// migrated into an encrypted one
+	if s.cipher != nil && !IsEncrypted(old) {
+		if old, err = s.cipher.Encrypt(old); err != nil {
+			return fmt.Errorf("fail to encrypt history of result file %s: %w", id, err)
+		}
+	}
+
+	revision := filepath.Join(revisionDir, strconv.FormatInt(time.Now().UnixNano(), 10)+".json")
+	if err := os.WriteFile(revision, old, 0600); err != nil {
+		return fmt.Errorf("fail to write history of result file %s: %w", id, err)
+	}
+
+	return s.replaceResultFile(id, b)
+}
+
+// replaceResultFile replaces the result file with the encoded result. The 0644
+// result files written by older versions are replaced by 0600 ones, such as
+// when a cleartext store is migrated into an encrypted one.
+func (s *dirStore) replaceResultFile(id string, b []byte) error {
+	if err := writeFileAtomic(s.path(id), b, 0600, true); err != nil {
+		return fmt.Errorf("fail to write result file %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *dirStore) Get(id string) (*Result, error) {
+	return s.readResultFile(s.path(id), id)
+}
+
+func (s *dirStore) List(filter *Filter) ([]*Entry, error) {
+	// the store directory is created by the first inserted result
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*Entry, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read the store directory: %w", err)
+	}
+
+	// the files are sorted by file name
+	entries := make([]*Entry, 0)
+	skipped := &SkippedResultsError{}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		r, err := s.Get(file.Name())
+		if err != nil {
+			skipped.skip(file.Name(), err)
+			continue
+		}
+
+		if filter.Match(r) {
+			entries = append(entries, &Entry{Id: file.Name(), Result: r})
+		}
+	}
+
+	return entries, skipped.err()
+}
+
+func (s *dirStore) History(id string) ([]*Revision, error) {
+	if _, err := os.Stat(s.path(id)); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+
+	revisionDir := filepath.Join(s.dir, historyDir, id)
+
+	files, err := os.ReadDir(revisionDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read history of result file %s: %w", id, err)
+	}
+
+	revisions := make([]*Revision, 0, len(files))
+	for _, file := range files {
+		nano, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		r, err := s.readResultFile(filepath.Join(revisionDir, file.Name()), id)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, &Revision{ReplacedAt: time.Unix(0, nano), Result: r})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ReplacedAt.Before(revisions[j].ReplacedAt)
+	})
+
+	return revisions, nil
+}
+
+// Remove moves the result file into the trash directory, the history of the
+// result is kept
+func (s *dirStore) Remove(id string) error {
+	fileName := s.path(id)
+	if _, err := os.Stat(fileName); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+
+	if err := os.MkdirAll(filepath.Join(s.dir, trashDir), 0755); err != nil {
+		return fmt.Errorf("fail to mkdir trash directory: %w", err)
+	}
+
+	// a removed result of the same id in the trash is not overwritten
+	if err := moveFile(fileName, s.trashPath(id)); err != nil {
+		return fmt.Errorf("fail to move result file %s into trash: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *dirStore) Close() error {
+	return nil
+}
+
+func (s *dirStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id))
+}
+
+func (s *dirStore) trashPath(id string) string {
+	return filepath.Join(s.dir, trashDir, filepath.Base(id))
+}
+
+func (s *dirStore) readResultFile(fileName string, id string) (*Result, error) {
+	b, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to read result file %s: %w", id, err)
+	}
+
+	r, err := DecodeWith(b, s.cipher)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode result file %s: %w", id, err)
+	}
+
+	return r, nil
+}
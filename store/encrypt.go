@@ -0,0 +1,203 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedResult is an encrypted result file, the ciphertext is the AES-GCM
+// encryption of the encoded result
+type encryptedResult struct {
+	Encryption *encryption `json:"encryption"`
+	Ciphertext []byte      `json:"ciphertext"`
+}
+
+type encryption struct {
+	Algorithm string `json:"algorithm"`
+	// KDF derives the key from the secret, sha256 for key files and scrypt
+	// with the salt for passphrases
+	KDF   string `json:"kdf"`
+	Salt  []byte `json:"salt,omitempty"`
+	Nonce []byte `json:"nonce"`
+}
+
+const (
+	encryptionAlgorithm = "aes-256-gcm"
+	kdfSHA256           = "sha256"
+	kdfScrypt           = "scrypt"
+)
+
+var (
+	ErrEncryptedResult        = errors.New("encrypted result, a key file or a passphrase is required")
+	ErrInvalidEncryption      = errors.New("invalid encryption")
+	ErrFailToDecryptResult    = errors.New("fail to decrypt result, wrong key or modified result")
+	ErrEmptyEncryptionKey     = errors.New("empty encryption key")
+	ErrAmbiguousEncryptionKey = errors.New("expect either a key file or a passphrase")
+)
+
+// Cipher encrypts and decrypts the results with a key file or a passphrase
+type Cipher struct {
+	secret []byte
+	kdf    string
+
+	// keys caches the derived keys by the salt
+	keys map[string][]byte
+}
+
+// LoadCipher returns the cipher of the key file, or asks for the passphrase
+// if `passphrase` is set, returns nil if neither is given
+func LoadCipher(keyFile string, passphrase bool) (*Cipher, error) {
+	switch {
+	case keyFile != "" && passphrase:
+		return nil, ErrAmbiguousEncryptionKey
+	case keyFile != "":
+		key, err := readKeyFile(keyFile, ErrEmptyEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Cipher{secret: key, kdf: kdfSHA256, keys: make(map[string][]byte)}, nil
+	case passphrase:
+		var value string
+		if err := survey.AskOne(&survey.Password{Message: "Enter the store passphrase:"}, &value); err != nil {
+			return nil, fmt.Errorf("fail to ask passphrase: %w", err)
+		}
+
+		if value == "" {
+			return nil, ErrEmptyEncryptionKey
+		}
+
+		return &Cipher{secret: []byte(value), kdf: kdfScrypt, keys: make(map[string][]byte)}, nil
+	}
+
+	return nil, nil
+}
+
+// IsEncrypted returns true if the data is an encrypted result
+func IsEncrypted(data []byte) bool {
+	var e struct {
+		Encryption *encryption `json:"encryption"`
+	}
+
+	return json.Unmarshal(data, &e) == nil && e.Encryption != nil
+}
+
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	e := &encryption{Algorithm: encryptionAlgorithm, KDF: c.kdf}
+
+	if c.kdf == kdfScrypt {
+		e.Salt = make([]byte, 16)
+		if _, err := rand.Read(e.Salt); err != nil {
+			return nil, fmt.Errorf("fail to generate salt: %w", err)
+		}
+	}
+
+	aead, err := c.aead(e)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return nil, fmt.Errorf("fail to generate nonce: %w", err)
+	}
+
+	return json.Marshal(&encryptedResult{Encryption: e, Ciphertext: aead.Seal(nil, e.Nonce, plaintext, nil)})
+}
+
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	var r encryptedResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("fail to decode encrypted result: %w", err)
+	}
+
+	if r.Encryption == nil || r.Encryption.Algorithm != encryptionAlgorithm {
+		return nil, ErrInvalidEncryption
+	}
+
+	aead, err := c.aead(r.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Encryption.Nonce) != aead.NonceSize() {
+		return nil, ErrInvalidEncryption
+	}
+
+	plaintext, err := aead.Open(nil, r.Encryption.Nonce, r.Ciphertext, nil)
+	if err != nil {
+		return nil, ErrFailToDecryptResult
+	}
+
+	return plaintext, nil
+}
+
+// aead derives the key by the kdf of the encryption, the kdf of a decrypted
+// result may differ from the kdf of the cipher, such as a passphrase written
+// into a key file
+func (c *Cipher) aead(e *encryption) (cipher.AEAD, error) {
+	cacheKey := e.KDF + ":" + string(e.Salt)
+
+	key, ok := c.keys[cacheKey]
+	if !ok {
+		switch e.KDF {
+		case kdfSHA256:
+			sum := sha256.Sum256(c.secret)
+			key = sum[:]
+		case kdfScrypt:
+			var err error
+			if key, err = scrypt.Key(c.secret, e.Salt, 1<<15, 8, 1, 32); err != nil {
+				return nil, fmt.Errorf("fail to derive key: %w", err)
+			}
+		default:
+			return nil, ErrInvalidEncryption
+		}
+
+		c.keys[cacheKey] = key
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encodeResult encodes the result, and encrypts it if the cipher is not nil
+func encodeResult(r *Result, c *Cipher) ([]byte, error) {
+	b, err := Encode(r)
+	if err != nil {
+		return nil, fmt.Errorf("fail to marshal result: %w", err)
+	}
+
+	if c == nil {
+		return b, nil
+	}
+
+	return c.Encrypt(b)
+}
+
+// DecodeWith decodes the result, and decrypts it first if it is encrypted
+func DecodeWith(data []byte, c *Cipher) (*Result, error) {
+	if IsEncrypted(data) {
+		if c == nil {
+			return nil, ErrEncryptedResult
+		}
+
+		var err error
+		if data, err = c.Decrypt(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return Decode(data)
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrResultFileExists = errors.New("result file exists")
+
+// unsafeFileNameChars is replaced in the components of the result file names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// newResultFileName returns a unique name `<name>_<student>_<ta>_<unix>_<random>.json`,
+// the empty components are left out. The student is left out of encrypted
+// results, as the file name is not encrypted.
+func newResultFileName(r *Result, encrypted bool) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("fail to generate random suffix: %w", err)
+	}
+
+	student := r.Meta.Student
+	if encrypted {
+		student = ""
+	}
+
+	components := make([]string, 0, 5)
+	for _, c := range []string{r.Config.Name, student, r.Meta.TA} {
+		if c = strings.Trim(unsafeFileNameChars.ReplaceAllString(c, "-"), "-"); c != "" {
+			components = append(components, c)
+		}
+	}
+	components = append(components, strconv.FormatInt(time.Now().Unix(), 10), hex.EncodeToString(suffix))
+
+	return strings.Join(components, "_") + ".json", nil
+}
+
+// writeFileAtomic writes the data into a temporary file in the same directory
+// first, so that a result file is never partially written. The file is
+// replaced if `overwrite` is set, otherwise `ErrResultFileExists` is returned
+// if the file exists.
+func writeFileAtomic(fileName string, data []byte, perm os.FileMode, overwrite bool) error {
+	f, err := os.CreateTemp(filepath.Dir(fileName), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("fail to create temporary file: %w", err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("fail to write temporary file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("fail to sync temporary file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("fail to close temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tmp, perm); err != nil {
+		return fmt.Errorf("fail to chmod temporary file: %w", err)
+	}
+
+	if overwrite {
+		return os.Rename(tmp, fileName)
+	}
+
+	// a hard link fails if the file exists, while a rename replaces it
+	if err := os.Link(tmp, fileName); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s: %w", filepath.Base(fileName), ErrResultFileExists)
+		}
+
+		// the filesystems without hard links, such as FAT drives and some
+		// synced folders, create the file exclusively instead
+		return writeFileExclusive(fileName, data, perm)
+	}
+
+	return nil
+}
+
+// writeFileExclusive creates and writes the file, `ErrResultFileExists` is
+// returned if the file exists. Unlike `writeFileAtomic`, a partially written
+// file is removed instead of never being seen.
+func writeFileExclusive(fileName string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s: %w", filepath.Base(fileName), ErrResultFileExists)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to create file: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(fileName)
+		return fmt.Errorf("fail to write file: %w", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(fileName)
+		return fmt.Errorf("fail to sync file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// moveFile moves the file without replacing an existing file at the target,
+// `ErrResultFileExists` is returned if the target exists
+func moveFile(from string, to string) error {
+	err := os.Link(from, to)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s: %w", to, ErrResultFileExists)
+	}
+
+	// the filesystems without hard links reserve the target by an empty file
+	// instead, which is then replaced by the rename
+	if err != nil {
+		f, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s: %w", to, ErrResultFileExists)
+		}
+		if err != nil {
+			return err
+		}
+		f.Close()
+
+		if err := os.Rename(from, to); err != nil {
+			os.Remove(to)
+			return err
+		}
+
+		return nil
+	}
+
+	return os.Remove(from)
+}
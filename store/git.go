@@ -0,0 +1,195 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+var (
+	ErrNotGitRepository   = errors.New("store directory is not in a git repository")
+	ErrResultNotCommitted = errors.New("result stored but not committed")
+)
+
+// WarnNotCommitted warns the result stored but failed to commit and returns
+// nil, as the result file is kept in the store, other errors are returned as
+// is
+func WarnNotCommitted(err error) error {
+	if !errors.Is(err, ErrResultNotCommitted) {
+		return err
+	}
+
+	pterm.Warning.Println("Commit the result file manually:", err)
+
+	return nil
+}
+
+// gitStore stores the result files in a directory of a git repository and
+// commits each inserted or updated result file, the history of a result is
+// the git log of the file
+type gitStore struct {
+	dirStore
+}
+
+// OpenGit opens the directory of result files inside a git repository, the
+// directory is created if not exists
+func OpenGit(dir string, c *Cipher) (Store, error) {
+	if IsSQLite(dir) {
+		return nil, fmt.Errorf("fail to open sqlite store %s in git: %w", dir, ErrNotGitRepository)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("fail to mkdir store directory: %w", err)
+	}
+
+	s := &gitStore{dirStore{dir: dir, cipher: c}}
+	if _, err := s.git("rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", dir, ErrNotGitRepository, err)
+	}
+
+	return s, nil
+}
+
+// Insert writes the result file and commits it, the id is returned with
+// `ErrResultNotCommitted` if the commit fails
+func (s *gitStore) Insert(r *Result) (string, error) {
+	id, err := s.dirStore.Insert(r)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.commit("Record", r, id); err != nil {
+		return id, err
+	}
+
+	return id, nil
+}
+
+// Update replaces the result file and commits it, the replaced result is kept
+// in git instead of the history directory
+func (s *gitStore) Update(id string, r *Result) error {
+	_, err := os.Stat(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to stat result file %s: %w", id, err)
+	}
+
+	b, err := encodeResult(r, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	if err := s.replaceResultFile(id, b); err != nil {
+		return err
+	}
+
+	return s.commit("Edit", r, id)
+}
+
+// Remove moves the result file into the trash directory and commits both
+// paths
+func (s *gitStore) Remove(id string) error {
+	r, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.dirStore.Remove(id); err != nil {
+		return err
+	}
+
+	return s.commit("Remove", r, id, filepath.Join(trashDir, filepath.Base(id)))
+}
+
+// History returns the committed versions of the result file before the
+// latest commit, replaced at the time of the following commit
+func (s *gitStore) History(id string) ([]*Revision, error) {
+	if _, err := os.Stat(s.path(id)); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+
+	out, err := s.git("log", "--format=%H %ct", "--", id)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get git log of result file %s: %w", id, err)
+	}
+
+	// the commits are listed newest first
+	commits := strings.Fields(out)
+	revisions := make([]*Revision, 0)
+	for i := 2; i+1 < len(commits); i += 2 {
+		replacedAt, err := strconv.ParseInt(commits[i-1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse git log of result file %s: %w", id, err)
+		}
+
+		data, err := s.git("show", commits[i]+":./"+id)
+		if err != nil {
+			return nil, fmt.Errorf("fail to show result file %s at %s: %w", id, commits[i], err)
+		}
+
+		r, err := DecodeWith([]byte(data), s.cipher)
+		if err != nil {
+			return nil, fmt.Errorf("fail to decode result file %s at %s: %w", id, commits[i], err)
+		}
+
+		revisions = append([]*Revision{{ReplacedAt: time.Unix(replacedAt, 0), Result: r}}, revisions...)
+	}
+
+	return revisions, nil
+}
+
+// commit commits only the paths of the result file, leaving other staged
+// changes of the repository alone. The error wraps `ErrResultNotCommitted`,
+// as the result file is already written.
+func (s *gitStore) commit(action string, r *Result, paths ...string) error {
+	if _, err := s.git(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return fmt.Errorf("%s: %w: fail to git add: %v", paths[0], ErrResultNotCommitted, err)
+	}
+
+	if _, err := s.git(append([]string{"commit", "-m", commitMessage(action, r, s.cipher != nil), "--"}, paths...)...); err != nil {
+		return fmt.Errorf("%s: %w: fail to git commit: %v", paths[0], ErrResultNotCommitted, err)
+	}
+
+	return nil
+}
+
+// commitMessage describes the config name, the student and the TA of the
+// result, such as `Record example: 110062000 by ta1`. The student is left
+// out of the encrypted results, as the result file names.
+func commitMessage(action string, r *Result, encrypted bool) string {
+	student := r.Meta.Student
+	if student == "" || encrypted {
+		student = "unknown student"
+	}
+
+	ta := r.Meta.TA
+	if ta == "" {
+		ta = r.Meta.CreatedBy
+	}
+
+	return fmt.Sprintf("%s %s: %s by %s", action, r.Config.Name, student, ta)
+}
+
+func (s *gitStore) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", append([]string{"-C", s.dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func newTestGitStore(t *testing.T) Store {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "ta1"},
+		{"config", "user.email", "ta1@example.com"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("fail to git %v: %v: %s", args, err, out)
+		}
+	}
+
+	s, err := OpenGit(dir, nil)
+	if err != nil {
+		t.Fatalf("fail to open git store: %v", err)
+	}
+
+	return s
+}
+
+func TestGitStoreHistory(t *testing.T) {
+	s := newTestGitStore(t)
+	defer s.Close()
+
+	r := &Result{
+		Meta:    Meta{TA: "ta1", Student: "110062000", SchemaVersion: SchemaVersion},
+		Answers: map[string]*Answer{"score": NumberAnswer(1)},
+		Config:  Config{Name: "example"},
+	}
+
+	id, err := s.Insert(r)
+	if err != nil {
+		t.Fatalf("fail to insert result: %v", err)
+	}
+
+	for _, score := range []float64{2, 3} {
+		r.Answers["score"] = NumberAnswer(score)
+		if err := s.Update(id, r); err != nil {
+			t.Fatalf("fail to update result: %v", err)
+		}
+	}
+
+	revisions, err := s.History(id)
+	if err != nil {
+		t.Fatalf("fail to get history: %v", err)
+	}
+
+	if len(revisions) != 2 {
+		t.Fatalf("expect 2 revisions, got %d", len(revisions))
+	}
+
+	for i, score := range []float64{1, 2} {
+		if got := revisions[i].Result.Answers["score"].Value; got != score {
+			t.Fatalf("expect score %v of revision %d, got %v", score, i, got)
+		}
+	}
+
+	latest, err := s.Get(id)
+	if err != nil {
+		t.Fatalf("fail to get result: %v", err)
+	}
+
+	if got := latest.Answers["score"].Value; got != float64(3) {
+		t.Fatalf("expect latest score 3, got %v", got)
+	}
+}
+
+func TestShowCommandOpensGitStore(t *testing.T) {
+	cmd := newShowCommand(nil)
+	if cmd.Flags().Lookup("store-git") == nil {
+		t.Fatal("expect show command to accept --store-git")
+	}
+}
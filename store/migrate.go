@@ -0,0 +1,301 @@
+package store
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+//go:embed assets
+var storeFS embed.FS
+
+var (
+	storeDir    string
+	dryRun      bool
+	signKeyFile string
+	encryptKey  string
+	passphrase  bool
+)
+
+var ErrSignedResultWithoutKey = errors.New("signed result requires --sign-key to migrate")
+
+// migration declares how to update result files when the keys of a record
+// config change. The applied migrations are recorded in the metadata so that
+// running a migration twice has no effect.
+type migration struct {
+	// Name is the record config name of the result files to migrate
+	Name    string             `json:"name"`
+	Renames []*migrationRename `json:"renames"`
+	Values  []*migrationValues `json:"values"`
+	Removes []string           `json:"removes"`
+
+	id      string
+	removes []*regexp.Regexp
+}
+
+type migrationRename struct {
+	Regexp string `json:"regexp"`
+	// Replace is the new key, which can refer to the submatches as `$1`
+	Replace string `json:"replace"`
+
+	regexp *regexp.Regexp
+}
+
+type migrationValues struct {
+	Regexp string `json:"regexp"`
+	// Map maps the old value, formatted as string, into the new value
+	Map map[string]interface{} `json:"map"`
+
+	regexp *regexp.Regexp
+}
+
+// migrationChange is a change of a key made by a migration
+type migrationChange struct {
+	key    string
+	change string
+}
+
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "migrate [path]",
+		Short:   "Apply a migration of keys and values to the result files",
+		Example: "demo store migrate example",
+		Args:    cobra.ExactArgs(1),
+		Run:     runMigrate,
+	}
+
+	cmd.Flags().StringVarP(&storeDir, "store", "s", "record/store", "The directory or the SQLite database (.db) of the results")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without writing the result files")
+	cmd.Flags().StringVar(&signKeyFile, "sign-key", "", "The TA key file to sign the migrated results, required to migrate signed results")
+	cmd.Flags().StringVar(&encryptKey, "encrypt-key", "", "The key file to decrypt the results and encrypt the migrated results")
+	cmd.Flags().BoolVar(&passphrase, "passphrase", false, "Ask for the passphrase to decrypt the results and encrypt the migrated results")
+	cmd.Flags().BoolVar(&storeGit, "store-git", false, "Commit the migrated result files into the git repository of the store directory")
+
+	return cmd
+}
+
+func runMigrate(_ *cobra.Command, args []string) {
+	m, err := loadMigrationFile(args[0])
+	if err != nil {
+		pterm.Fatal.Println("Fail to load migration file:", err)
+	}
+
+	var signKey []byte
+	if signKeyFile != "" {
+		if signKey, err = LoadSignKey(signKeyFile); err != nil {
+			pterm.Fatal.Println("Fail to load sign key:", err)
+		}
+	}
+
+	s := openStore()
+	defer s.Close()
+
+	entries, err := s.List(&Filter{Name: m.Name})
+	if err = WarnSkipped(err); err != nil {
+		pterm.Fatal.Println("Fail to list results:", err)
+	}
+
+	// every result is migrated before writing any of them, so that an invalid
+	// result does not leave the store half migrated
+	migrated := make([]*Entry, 0)
+	invalid := make([]error, 0)
+	for _, entry := range entries {
+		changes, err := m.migrate(entry.Result)
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s: %w", entry.Id, err))
+			continue
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		// a migrated result is signed again, or its signature would be invalid
+		if signKey == nil && entry.Result.Signature != "" {
+			invalid = append(invalid, fmt.Errorf("%s: %w", entry.Id, ErrSignedResultWithoutKey))
+			continue
+		}
+
+		migrated = append(migrated, entry)
+
+		pterm.DefaultSection.WithLevel(2).Println(entry.Id)
+		data := [][]string{{"Key", "Change"}}
+		for _, c := range changes {
+			data = append(data, []string{c.key, c.change})
+		}
+
+		if err := pterm.DefaultTable.WithHasHeader().WithData(data).Render(); err != nil {
+			pterm.Fatal.Println("Fail to render changes table:", err)
+		}
+	}
+
+	if dryRun {
+		for _, err := range invalid {
+			pterm.Warning.Println("Fail to migrate result:", err)
+		}
+
+		pterm.Info.Printf("%d results would be migrated, %d results fail to migrate.\n", len(migrated), len(invalid))
+		return
+	}
+
+	if len(invalid) > 0 {
+		for _, err := range invalid {
+			pterm.Error.Println("Fail to migrate result:", err)
+		}
+
+		pterm.Fatal.Printf("Fail to migrate %d results, no result is migrated.\n", len(invalid))
+	}
+
+	for _, entry := range migrated {
+		if signKey != nil {
+			if err := Sign(entry.Result, signKey); err != nil {
+				pterm.Fatal.Println("Fail to sign result:", err)
+			}
+		}
+
+		// the replaced result is kept in the history of the store
+		if err := WarnNotCommitted(s.Update(entry.Id, entry.Result)); err != nil {
+			pterm.Fatal.Println("Fail to update result:", err)
+		}
+	}
+
+	pterm.Success.Printf("%d results migrated.\n", len(migrated))
+}
+
+func loadMigrationFile(id string) (*migration, error) {
+	fileName := "assets/" + id + ".json"
+
+	f, err := storeFS.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read migration file %s: %w", fileName, err)
+	}
+
+	var m migration
+	if err := json.NewDecoder(bytes.NewReader(f)).Decode(&m); err != nil {
+		return nil, fmt.Errorf("fail to decode migration file %s: %w", fileName, err)
+	}
+
+	m.id = id
+
+	if err := m.compile(); err != nil {
+		return nil, fmt.Errorf("fail to compile migration %s: %w", fileName, err)
+	}
+
+	return &m, nil
+}
+
+func (m *migration) compile() error {
+	for _, rename := range m.Renames {
+		regexp, err := regexp.Compile(rename.Regexp)
+		if err != nil {
+			return fmt.Errorf("fail to compile rename %s: %w", rename.Regexp, err)
+		}
+
+		rename.regexp = regexp
+	}
+
+	for _, values := range m.Values {
+		regexp, err := regexp.Compile(values.Regexp)
+		if err != nil {
+			return fmt.Errorf("fail to compile values %s: %w", values.Regexp, err)
+		}
+
+		values.regexp = regexp
+	}
+
+	for _, remove := range m.Removes {
+		regexp, err := regexp.Compile(remove)
+		if err != nil {
+			return fmt.Errorf("fail to compile remove %s: %w", remove, err)
+		}
+
+		m.removes = append(m.removes, regexp)
+	}
+
+	return nil
+}
+
+// migrate applies the migration on the answers of the result, and upgrades
+// the result into the current schema version, returns no change if the
+// migration is applied before
+func (m *migration) migrate(result *Result) ([]*migrationChange, error) {
+	for _, id := range result.Meta.Migrations {
+		if id == m.id {
+			return nil, nil
+		}
+	}
+
+	changes := make([]*migrationChange, 0)
+
+	for _, k := range result.Keys() {
+		answer := result.Answers[k]
+
+		if m.isRemoved(k) {
+			delete(result.Answers, k)
+			changes = append(changes, &migrationChange{key: k, change: "removed"})
+			continue
+		}
+
+		for _, values := range m.Values {
+			if !values.regexp.MatchString(k) {
+				continue
+			}
+
+			if newValue, ok := values.Map[fmt.Sprintf("%v", answer.Value)]; ok {
+				changes = append(changes, &migrationChange{key: k, change: fmt.Sprintf("%v -> %v", answer.Value, newValue)})
+
+				// keep the option index and label, which refer to the record config
+				if answer.Type == AnswerTypeOption {
+					answer = &Answer{Type: AnswerTypeOption, Value: newValue, Label: answer.Label, Index: answer.Index}
+				} else {
+					answer = inferAnswer(newValue)
+				}
+				result.Answers[k] = answer
+			}
+			break
+		}
+
+		for _, rename := range m.Renames {
+			if !rename.regexp.MatchString(k) {
+				continue
+			}
+
+			newKey := rename.regexp.ReplaceAllString(k, rename.Replace)
+			if newKey != k {
+				if _, ok := result.Answers[newKey]; ok {
+					return nil, fmt.Errorf("fail to rename %s, key %s already exists", k, newKey)
+				}
+
+				delete(result.Answers, k)
+				result.Answers[newKey] = answer
+				changes = append(changes, &migrationChange{key: k, change: "renamed to " + newKey})
+			}
+			break
+		}
+	}
+
+	if result.Meta.SchemaVersion != SchemaVersion {
+		changes = append(changes, &migrationChange{key: "meta." + MetaKeySchemaVersion, change: fmt.Sprintf("%d -> %d", result.Meta.SchemaVersion, SchemaVersion)})
+		result.Meta.SchemaVersion = SchemaVersion
+	}
+
+	result.Meta.Migrations = append(result.Meta.Migrations, m.id)
+
+	return changes, nil
+}
+
+func (m *migration) isRemoved(key string) bool {
+	for _, remove := range m.removes {
+		if remove.MatchString(key) {
+			return true
+		}
+	}
+
+	return false
+}
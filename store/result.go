@@ -0,0 +1,232 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Result is the envelope of a result file, separating the metadata and the
+// record config from the recorded answers.
+//
+// Result files recorded before schema version 2 are flat JSON objects of the
+// answers, together with the metadata keys `<name>.<meta key>` where `<name>`
+// is the record config. They are still decoded by `Decode`, with the metadata
+// keys moved into `Meta`.
+type Result struct {
+	Meta    Meta               `json:"meta"`
+	Answers map[string]*Answer `json:"answers"`
+	Config  Config             `json:"config"`
+	// Signature is the signature of the result by the TA key, see `Sign`
+	Signature string `json:"signature,omitempty"`
+}
+
+type Meta struct {
+	CreatedAt string `json:"createdAt"`
+	CreatedBy string `json:"createdBy"`
+	// TA is the id of the TA, while CreatedBy is the display name
+	TA string `json:"ta"`
+	// Student is the key of the student, the answers of the identity prompts
+	Student  string `json:"student,omitempty"`
+	Hostname string `json:"hostname"`
+	// Duration is the time spent on the record session
+	Duration      string   `json:"duration"`
+	Version       string   `json:"version"`
+	SchemaVersion int      `json:"schemaVersion"`
+	Migrations    []string `json:"migrations,omitempty"`
+}
+
+type Config struct {
+	Name string `json:"name"`
+	// Hash is the sha256 of the record config
+	Hash string `json:"hash"`
+	// Labels is the prompt message of each answer key when recorded, shown by
+	// `store show` if the key is not in the record config anymore
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+const (
+	MetaKeyCreatedAt     = "createdAt"
+	MetaKeyCreatedBy     = "createdBy"
+	MetaKeyTA            = "ta"
+	MetaKeyStudent       = "student"
+	MetaKeyHostname      = "hostname"
+	MetaKeyDuration      = "duration"
+	MetaKeyVersion       = "version"
+	MetaKeySchemaVersion = "schemaVersion"
+	MetaKeyConfigHash    = "configHash"
+	MetaKeyMigrations    = "migrations"
+)
+
+// SchemaVersion is the version of the result file format, result files
+// without the schema version are recorded before version 1, result files
+// before version 2 are in the flat format, and answers before version 3 are
+// plain values without type.
+const SchemaVersion = 3
+
+var ErrInvalidResult = errors.New("invalid result")
+
+// Decode decodes a result file in either the envelope or the flat format
+func Decode(data []byte) (*Result, error) {
+	var envelope struct {
+		Meta      *Meta                      `json:"meta"`
+		Answers   map[string]json.RawMessage `json:"answers"`
+		Config    *Config                    `json:"config"`
+		Signature string                     `json:"signature"`
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&envelope); err == nil &&
+		envelope.Meta != nil && envelope.Answers != nil && envelope.Config != nil && envelope.Meta.SchemaVersion >= 2 {
+		r := &Result{Meta: *envelope.Meta, Answers: make(map[string]*Answer), Config: *envelope.Config, Signature: envelope.Signature}
+
+		for k, raw := range envelope.Answers {
+			if r.Meta.SchemaVersion >= 3 {
+				answer := &Answer{}
+				if err := json.Unmarshal(raw, answer); err != nil {
+					return nil, fmt.Errorf("fail to decode answer %s: %w", k, err)
+				}
+
+				r.Answers[k] = answer
+				continue
+			}
+
+			var value interface{}
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("fail to decode answer %s: %w", k, err)
+			}
+
+			r.Answers[k] = inferAnswer(value)
+		}
+
+		return r, nil
+	}
+
+	flat := make(map[string]interface{})
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&flat); err != nil {
+		return nil, fmt.Errorf("fail to decode result: %w", err)
+	}
+
+	return fromFlat(flat)
+}
+
+// Encode encodes the result in the envelope format. The answers are typed
+// once decoded, so results decoded from older formats are encoded with the
+// current schema version.
+func Encode(r *Result) ([]byte, error) {
+	e := *r
+	if e.Meta.SchemaVersion < SchemaVersion {
+		e.Meta.SchemaVersion = SchemaVersion
+	}
+
+	return json.Marshal(&e)
+}
+
+// fromFlat converts a result in the flat format, the record config name is
+// found by the `<name>.createdAt` key
+func fromFlat(flat map[string]interface{}) (*Result, error) {
+	name := ""
+	for k := range flat {
+		if strings.HasSuffix(k, "."+MetaKeyCreatedAt) {
+			name = strings.TrimSuffix(k, "."+MetaKeyCreatedAt)
+			break
+		}
+	}
+
+	r := &Result{
+		Answers: make(map[string]*Answer),
+		Config:  Config{Name: name},
+	}
+
+	metaStrings := map[string]*string{
+		MetaKeyCreatedAt:  &r.Meta.CreatedAt,
+		MetaKeyCreatedBy:  &r.Meta.CreatedBy,
+		MetaKeyTA:         &r.Meta.TA,
+		MetaKeyHostname:   &r.Meta.Hostname,
+		MetaKeyDuration:   &r.Meta.Duration,
+		MetaKeyVersion:    &r.Meta.Version,
+		MetaKeyConfigHash: &r.Config.Hash,
+	}
+
+	for k, v := range flat {
+		if name == "" || !strings.HasPrefix(k, name+".") {
+			r.Answers[k] = inferAnswer(v)
+			continue
+		}
+
+		metaKey := strings.TrimPrefix(k, name+".")
+
+		if p, ok := metaStrings[metaKey]; ok {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expect metadata %s to be a string: %w", k, ErrInvalidResult)
+			}
+
+			*p = s
+			continue
+		}
+
+		switch metaKey {
+		case MetaKeySchemaVersion:
+			version, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("expect metadata %s to be a number: %w", k, ErrInvalidResult)
+			}
+
+			r.Meta.SchemaVersion = int(version)
+		case MetaKeyMigrations:
+			migrations, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expect metadata %s to be a list: %w", k, ErrInvalidResult)
+			}
+
+			for _, migration := range migrations {
+				r.Meta.Migrations = append(r.Meta.Migrations, fmt.Sprintf("%v", migration))
+			}
+		default:
+			r.Answers[k] = inferAnswer(v)
+		}
+	}
+
+	return r, nil
+}
+
+// Flatten returns the answer values keyed by the answer keys, without the
+// metadata. The values of multiselect answers are joined into a string.
+func (r *Result) Flatten() map[string]interface{} {
+	flat := make(map[string]interface{})
+
+	for k, answer := range r.Answers {
+		flat[k] = answer.Field(AnswerFieldValue)
+	}
+
+	return flat
+}
+
+// Metadata returns the string metadata keyed by the meta keys, such as
+// `createdAt`
+func (r *Result) Metadata() map[string]string {
+	return map[string]string{
+		MetaKeyCreatedAt:  r.Meta.CreatedAt,
+		MetaKeyCreatedBy:  r.Meta.CreatedBy,
+		MetaKeyTA:         r.Meta.TA,
+		MetaKeyStudent:    r.Meta.Student,
+		MetaKeyHostname:   r.Meta.Hostname,
+		MetaKeyDuration:   r.Meta.Duration,
+		MetaKeyVersion:    r.Meta.Version,
+		MetaKeyConfigHash: r.Config.Hash,
+	}
+}
+
+// Keys returns the sorted keys of the answers
+func (r *Result) Keys() []string {
+	keys := make([]string, 0, len(r.Answers))
+	for k := range r.Answers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signaturePrefix is the algorithm of the signature, followed by the hex
+// encoded HMAC
+const signaturePrefix = "hmac-sha256:"
+
+var (
+	ErrEmptySignKey     = errors.New("empty sign key")
+	ErrUnsignedResult   = errors.New("unsigned result")
+	ErrInvalidSignature = errors.New("invalid signature, the result is modified or signed by another key")
+)
+
+// LoadSignKey reads the TA key from the file, the surrounding spaces are
+// trimmed
+func LoadSignKey(fileName string) ([]byte, error) {
+	return readKeyFile(fileName, ErrEmptySignKey)
+}
+
+func readKeyFile(fileName string, errEmpty error) ([]byte, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read key file %s: %w", fileName, err)
+	}
+
+	key := bytes.TrimSpace(b)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s: %w", fileName, errEmpty)
+	}
+
+	return key, nil
+}
+
+// Sign signs the result with the key. The result is signed as it is encoded,
+// so it should be in the current schema version.
+func Sign(r *Result, key []byte) error {
+	signature, err := sign(r, key)
+	if err != nil {
+		return err
+	}
+
+	r.Signature = signature
+
+	return nil
+}
+
+// Verify returns an error if the result is unsigned, or the signature does
+// not match the content of the result
+func Verify(r *Result, key []byte) error {
+	if r.Signature == "" {
+		return ErrUnsignedResult
+	}
+
+	expected, err := sign(r, key)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(r.Signature, signaturePrefix) || !hmac.Equal([]byte(r.Signature), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// sign returns the HMAC of the canonical content of the result, which is the
+// json encoding without the signature, the keys of the maps are sorted by the
+// json encoding
+func sign(r *Result, key []byte) (string, error) {
+	c := *r
+	c.Signature = ""
+
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return "", fmt.Errorf("fail to marshal result to sign: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(b)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
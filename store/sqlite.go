@@ -0,0 +1,300 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema keeps the metadata filtered by `List` as columns, next to the
+// result envelope stored as json
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	name       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	ta         TEXT NOT NULL,
+	version    TEXT NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS result_history (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	result_id   INTEGER NOT NULL REFERENCES results (id),
+	replaced_at TEXT NOT NULL,
+	data        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS result_history_result_id ON result_history (result_id);
+CREATE TABLE IF NOT EXISTS result_trash (
+	id         INTEGER PRIMARY KEY,
+	removed_at TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	ta         TEXT NOT NULL,
+	version    TEXT NOT NULL,
+	data       TEXT NOT NULL
+);
+`
+
+// sqliteStore stores the results in a SQLite database, the id of a result is
+// the row id. The data of the results is encrypted if the cipher is not nil,
+// while the metadata columns are not.
+type sqliteStore struct {
+	db     *sql.DB
+	cipher *Cipher
+}
+
+func openSQLite(path string, c *Cipher) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("fail to mkdir store directory: %w", err)
+	}
+
+	// the database is created with the same permissions as the result files
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("fail to create sqlite store %s: %w", path, err)
+		}
+		f.Close()
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open sqlite store %s: %w", path, err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("fail to create sqlite store schema %s: %w", path, err)
+	}
+
+	return &sqliteStore{db: db, cipher: c}, nil
+}
+
+func (s *sqliteStore) Insert(r *Result) (string, error) {
+	b, err := encodeResult(r, s.cipher)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := s.db.Exec(
+		"INSERT INTO results (name, created_at, created_by, ta, version, data) VALUES (?, ?, ?, ?, ?, ?)",
+		r.Config.Name, r.Meta.CreatedAt, r.Meta.CreatedBy, r.Meta.TA, r.Meta.Version, string(b),
+	)
+	if err != nil {
+		return "", fmt.Errorf("fail to insert result: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("fail to get id of inserted result: %w", err)
+	}
+
+	return strconv.FormatInt(id, 10), nil
+}
+
+func (s *sqliteStore) Update(id string, r *Result) error {
+	b, err := encodeResult(r, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO result_history (result_id, replaced_at, data) SELECT id, ?, data FROM results WHERE id = ?",
+		time.Now().Format(time.RFC3339Nano), id,
+	)
+	if err != nil {
+		return fmt.Errorf("fail to insert history of result %s: %w", id, err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("fail to insert history of result %s: %w", id, err)
+	} else if n == 0 {
+		return fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE results SET name = ?, created_at = ?, created_by = ?, ta = ?, version = ?, data = ? WHERE id = ?",
+		r.Config.Name, r.Meta.CreatedAt, r.Meta.CreatedBy, r.Meta.TA, r.Meta.Version, string(b), id,
+	); err != nil {
+		return fmt.Errorf("fail to update result %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("fail to commit update of result %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) Get(id string) (*Result, error) {
+	var data string
+	err := s.db.QueryRow("SELECT data FROM results WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fail to get result %s: %w", id, err)
+	}
+
+	return s.decodeRow(id, data)
+}
+
+func (s *sqliteStore) List(filter *Filter) ([]*Entry, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	if filter != nil {
+		for column, value := range map[string]string{
+			"name":       filter.Name,
+			"created_by": filter.CreatedBy,
+			"ta":         filter.TA,
+			"version":    filter.Version,
+		} {
+			if value != "" {
+				conditions = append(conditions, column+" = ?")
+				args = append(args, value)
+			}
+		}
+	}
+
+	query := "SELECT id, data FROM results"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("fail to list results: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*Entry, 0)
+	skipped := &SkippedResultsError{}
+	for rows.Next() {
+		var (
+			id   int64
+			data string
+		)
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("fail to scan result: %w", err)
+		}
+
+		r, err := s.decodeRow(strconv.FormatInt(id, 10), data)
+		if err != nil {
+			skipped.skip(strconv.FormatInt(id, 10), err)
+			continue
+		}
+
+		// the created at time is compared after parsing, as the time zones of
+		// the results may differ
+		if filter.Match(r) {
+			entries = append(entries, &Entry{Id: strconv.FormatInt(id, 10), Result: r})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("fail to list results: %w", err)
+	}
+
+	return entries, skipped.err()
+}
+
+func (s *sqliteStore) History(id string) ([]*Revision, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query("SELECT replaced_at, data FROM result_history WHERE result_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get history of result %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	revisions := make([]*Revision, 0)
+	for rows.Next() {
+		var replacedAt, data string
+		if err := rows.Scan(&replacedAt, &data); err != nil {
+			return nil, fmt.Errorf("fail to scan history of result %s: %w", id, err)
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, replacedAt)
+		if err != nil {
+			return nil, fmt.Errorf("fail to parse history time of result %s: %w", id, err)
+		}
+
+		r, err := s.decodeRow(id, data)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, &Revision{ReplacedAt: t, Result: r})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("fail to get history of result %s: %w", id, err)
+	}
+
+	return revisions, nil
+}
+
+// Remove moves the row into the trash table with the same id, the history of
+// the result is kept
+func (s *sqliteStore) Remove(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("fail to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"INSERT INTO result_trash (id, removed_at, name, created_at, created_by, ta, version, data) SELECT id, ?, name, created_at, created_by, ta, version, data FROM results WHERE id = ?",
+		time.Now().Format(time.RFC3339Nano), id,
+	)
+	if err != nil {
+		return fmt.Errorf("fail to move result %s into trash: %w", id, err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("fail to move result %s into trash: %w", id, err)
+	} else if n == 0 {
+		return fmt.Errorf("%s: %w", id, ErrResultNotFound)
+	}
+
+	if _, err := tx.Exec("DELETE FROM results WHERE id = ?", id); err != nil {
+		return fmt.Errorf("fail to remove result %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("fail to commit removal of result %s: %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) decodeRow(id string, data string) (*Result, error) {
+	r, err := DecodeWith([]byte(data), s.cipher)
+	if err != nil {
+		return nil, fmt.Errorf("fail to decode result %s: %w", id, err)
+	}
+
+	return r, nil
+}
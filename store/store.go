@@ -0,0 +1,26 @@
+package store
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// LabelLoader returns the prompt messages of the answer keys from the record
+// config `name`
+type LabelLoader func(name string, answers map[string]*Answer) (map[string]string, error)
+
+// NewStoreCommand creates the store command, the labels of the answers shown
+// by `store show` are loaded by `loadLabels` as the record configs are in the
+// record package
+func NewStoreCommand(loadLabels LabelLoader) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "store [command]",
+		Short: "Manage the result files in the store",
+	}
+
+	cmd.AddCommand(newListCommand())
+	cmd.AddCommand(newShowCommand(loadLabels))
+	cmd.AddCommand(newRemoveCommand())
+	cmd.AddCommand(newMigrateCommand())
+
+	return cmd
+}